@@ -0,0 +1,106 @@
+/*
+Copyright © 2020 John Laswell
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jlaswell/compote/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var autoloadCmdShort = "Inspect how packages are autoloaded"
+var autoloadCmdLong = autoloadCmdShort + `
+
+Examples:
+  # Check that every class can be loaded by its PSR-4 or PSR-0 mapping.
+  compote autoload check
+
+  # Report issues as JSON, leaving out development packages.
+  compote autoload check --no-dev --format json`
+
+// autoloadCmd represents the autoload command
+var autoloadCmd = &cobra.Command{
+	Use:   "autoload",
+	Short: autoloadCmdShort,
+	Long:  autoloadCmdLong,
+}
+
+var autoloadCheckCmdShort = "Check classes against their PSR-4 and PSR-0 mappings"
+var autoloadCheckCmdLong = autoloadCheckCmdShort + `
+
+Scans every PSR-4 and PSR-0 directory of composer.json and of the
+packages installed from the lockfile. Classes whose namespace or
+filename does not match where their mapping would load them from
+are reported, along with classes declared by more than one package.
+Exits with a non-zero status when any issue is found.`
+
+var autoloadCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: autoloadCheckCmdShort,
+	Long:  autoloadCheckCmdLong,
+	Args:  cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("no-dev", cmd.Flags().Lookup("no-dev"))
+	},
+	Run: runAutoloadCheckCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(autoloadCmd)
+	autoloadCmd.AddCommand(autoloadCheckCmd)
+
+	autoloadCheckCmd.Flags().StringP("format", "", "text", "Output format: text or json")
+	autoloadCheckCmd.Flags().BoolP("no-dev", "", false, "Leave out development packages and the root autoload-dev section")
+}
+
+func runAutoloadCheckCmd(cmd *cobra.Command, args []string) {
+	file, err := pkg.LoadFile(viper.GetString("filepath"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	report, err := pkg.CheckAutoload(file, !viper.GetBool("no-dev"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "json":
+		reportJSON, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(string(reportJSON))
+	case "text":
+		if !viper.GetBool("quiet") {
+			fmt.Print(report)
+		}
+	default:
+		fmt.Printf("Unknown format %q\n", format)
+		os.Exit(1)
+	}
+	if !report.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2020 John Laswell
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/jlaswell/compote/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cacheCmdShort = "Manage the local download cache"
+var cacheCmdLong = cacheCmdShort + `
+
+Verified dist archives are cached under $XDG_CACHE_HOME/compote
+by default so that later installs do not need to download them
+again. Use --cache-dir or the cache-dir config key to move it.
+
+Examples:
+  # List every cached archive.
+  compote cache list
+
+  # Remove archives that have not been used in the last 30 days.
+  compote cache prune --older-than 30d`
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: cacheCmdShort,
+	Long:  cacheCmdLong,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached archives",
+	Args:  cobra.NoArgs,
+	Run:   runCacheListCmd,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached archive",
+	Args:  cobra.NoArgs,
+	Run:   runCacheClearCmd,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached archives that have not been used recently",
+	Args:  cobra.NoArgs,
+	Run:   runCachePruneCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd, cacheClearCmd, cachePruneCmd)
+
+	cachePruneCmd.Flags().StringP("older-than", "", "720h", "Remove archives unused for this long, e.g. 72h or 30d")
+}
+
+func runCacheListCmd(cmd *cobra.Command, args []string) {
+	entries, err := openCache().Entries()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	t := table.NewWriter()
+	t.Style().Options = table.OptionsNoBordersAndSeparators
+	t.Style().Box.PaddingLeft = ""
+	t.Style().Box.PaddingRight = "  "
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"NAME", "KEY", "SIZE", "LAST USED"})
+	for _, e := range entries {
+		key := e.Key
+		if len(key) > 12 {
+			key = key[:12]
+		}
+		t.AppendRow(table.Row{e.Package, key, e.Size, e.LastUsed.Format(time.RFC3339)})
+	}
+	t.Render()
+}
+
+func runCacheClearCmd(cmd *cobra.Command, args []string) {
+	cache := openCache()
+	if err := cache.Clear(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !viper.GetBool("quiet") {
+		fmt.Printf("Cleared %s\n", cache.Dir)
+	}
+}
+
+func runCachePruneCmd(cmd *cobra.Command, args []string) {
+	olderThan, err := parseAge(cmd.Flags().Lookup("older-than").Value.String())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	pruned, err := openCache().Prune(olderThan)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !viper.GetBool("quiet") {
+		fmt.Printf("Pruned %d archives\n", len(pruned))
+	}
+}
+
+// openCache returns the cache configured by --cache-dir, falling back to the
+// default cache location.
+func openCache() *pkg.Cache {
+	dir := viper.GetString("cache-dir")
+	if dir == "" {
+		var err error
+		dir, err = pkg.DefaultCacheDir()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	return pkg.NewCache(dir)
+}
+
+// parseAge parses a duration, additionally accepting a number of days such
+// as "30d".
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(age)
+}
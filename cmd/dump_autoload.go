@@ -0,0 +1,104 @@
+/*
+Copyright © 2020 John Laswell
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jlaswell/compote/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dumpAutoloadCmdShort = "Regenerate the autoloader for installed packages"
+var dumpAutoloadCmdLong = dumpAutoloadCmdShort + `
+
+Install generates vendor/autoload.php on its own. Use dump-autoload
+to regenerate it for the packages already in vendor and the autoload
+sections of composer.json, such as after adding a namespace to the
+project or when building an optimized autoloader for production.
+
+--optimize-autoloader scans every PSR-4 and PSR-0 directory into
+the classmap. --classmap-authoritative does the same and stops the
+autoloader from looking for classes missing from the classmap.
+
+Examples:
+  # Regenerate the autoloader.
+  compote dump-autoload
+
+  # Build an authoritative classmap for production.
+  compote dump-autoload --no-dev --classmap-authoritative`
+
+// dumpAutoloadCmd represents the dump-autoload command
+var dumpAutoloadCmd = &cobra.Command{
+	Use:     "dump-autoload",
+	Aliases: []string{"dumpautoload"},
+	Short:   dumpAutoloadCmdShort,
+	Long:    dumpAutoloadCmdLong,
+	Args:    cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("no-dev", cmd.Flags().Lookup("no-dev"))
+		bindAutoloadFlags(cmd)
+	},
+	Run: runDumpAutoloadCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(dumpAutoloadCmd)
+
+	dumpAutoloadCmd.Flags().BoolP("no-dev", "", false, "Leave out development packages and the root autoload-dev section")
+	addAutoloadFlags(dumpAutoloadCmd)
+}
+
+func runDumpAutoloadCmd(cmd *cobra.Command, args []string) {
+	file, err := pkg.LoadFile(viper.GetString("filepath"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = pkg.DumpAutoload(file, !viper.GetBool("no-dev"), autoloadOptions(), viper.GetBool("quiet"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !viper.GetBool("quiet") {
+		fmt.Println("Generated autoload files")
+	}
+}
+
+// addAutoloadFlags adds the flags controlling how the autoloader is
+// generated to cmd.
+func addAutoloadFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("optimize-autoloader", "o", false, "Convert PSR-4 and PSR-0 autoloading to a classmap for a faster autoloader")
+	cmd.Flags().BoolP("classmap-authoritative", "a", false, "Autoload classes from the classmap only, implies --optimize-autoloader")
+}
+
+// bindAutoloadFlags binds the autoload flags of cmd. It is called from
+// PreRun since install and dump-autoload share the same config keys.
+func bindAutoloadFlags(cmd *cobra.Command) {
+	viper.BindPFlag("optimize-autoloader", cmd.Flags().Lookup("optimize-autoloader"))
+	viper.BindPFlag("classmap-authoritative", cmd.Flags().Lookup("classmap-authoritative"))
+}
+
+// autoloadOptions reads the autoload flags bound by bindAutoloadFlags.
+func autoloadOptions() pkg.AutoloadOptions {
+	return pkg.AutoloadOptions{
+		Optimize:      viper.GetBool("optimize-autoloader"),
+		Authoritative: viper.GetBool("classmap-authoritative"),
+	}
+}
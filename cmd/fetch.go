@@ -0,0 +1,84 @@
+/*
+Copyright © 2020 John Laswell
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jlaswell/compote/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var fetchCmdShort = "Download locked packages into the cache"
+var fetchCmdLong = fetchCmdShort + `
+
+Fetch downloads and verifies every package locked to this project
+into the local cache without creating or replacing the vendor
+directory. A later "compote install --offline" can then install
+from the cache alone.
+
+Examples:
+  # Warm the cache for the project in the current directory.
+  compote fetch
+
+  # Warm a cache directory that is shared between build stages.
+  compote fetch --cache-dir /tmp/compote-cache`
+
+// fetchCmd represents the fetch command
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: fetchCmdShort,
+	Long:  fetchCmdLong,
+	// Flags shared with install are bound when the command runs so that
+	// they do not replace the bindings for install's flags.
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("no-dev", cmd.Flags().Lookup("no-dev"))
+		viper.BindPFlag("jobs", cmd.Flags().Lookup("jobs"))
+		viper.BindPFlag("require-checksums", cmd.Flags().Lookup("require-checksums"))
+	},
+	Run: runFetchCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+
+	fetchCmd.Flags().BoolP("no-dev", "", false, "Skip fetching development packages")
+	fetchCmd.Flags().IntP("jobs", "j", pkg.DefaultJobs, "Number of packages to download at once")
+	fetchCmd.Flags().BoolP("require-checksums", "", false, "Fail when a locked package has no dist shasum to verify")
+}
+
+func runFetchCmd(cmd *cobra.Command, args []string) {
+	file, err := pkg.LoadFile(viper.GetString("filepath"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = pkg.Fetch(file, pkg.InstallOptions{
+		SkipDev:          viper.GetBool("no-dev"),
+		Quiet:            viper.GetBool("quiet"),
+		Jobs:             viper.GetInt("jobs"),
+		Download:         downloadOptions(file),
+		Cache:            openCache(),
+		RequireChecksums: viper.GetBool("require-checksums"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
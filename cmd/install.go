@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -32,23 +33,59 @@ By default, install requires a composer.lock file to be present
 in order to install packages. This prevents the mistake of
 updating packages when no lockfile is present.
 
+Downloads and extractions run in separate worker pools. Use
+--jobs and --extract-jobs, or the matching keys in .compote.yaml,
+to limit how much work happens at once.
+
 Examples:
   # Install package locked to this project.
-  compote install`
+  compote install
+
+  # Install with at most 4 concurrent downloads.
+  compote install --jobs 4
+
+  # Install using only previously cached archives.
+  compote install --offline
+
+  # Install with an authoritative classmap for production.
+  compote install --no-dev --classmap-authoritative
+
+  # Show what an install would change as JSON.
+  compote install --dry-run --format json`
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: installCmdShort,
 	Long:  installCmdLong,
-	Run:   runInstallCmd,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindAutoloadFlags(cmd)
+	},
+	Run: runInstallCmd,
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
 
+	installCmd.Flags().BoolP("dry-run", "", false, "Print the changes an install would make without making them")
+	installCmd.Flags().StringP("format", "", "text", "Output format for --dry-run: text or json")
 	installCmd.Flags().BoolP("no-dev", "", false, "Skip installation of development packages")
 	viper.BindPFlag("no-dev", installCmd.Flags().Lookup("no-dev"))
+	installCmd.Flags().IntP("jobs", "j", pkg.DefaultJobs, "Number of packages to download at once")
+	viper.BindPFlag("jobs", installCmd.Flags().Lookup("jobs"))
+	installCmd.Flags().IntP("extract-jobs", "", 0, "Number of packages to extract at once (default is the number of CPUs)")
+	viper.BindPFlag("extract-jobs", installCmd.Flags().Lookup("extract-jobs"))
+	installCmd.Flags().BoolP("no-cache", "", false, "Always download packages instead of using the local cache")
+	viper.BindPFlag("no-cache", installCmd.Flags().Lookup("no-cache"))
+	installCmd.Flags().BoolP("offline", "", false, "Install only from the cache and local archives without using the network")
+	viper.BindPFlag("offline", installCmd.Flags().Lookup("offline"))
+	installCmd.Flags().StringP("path-mode", "", string(pkg.PathModeSymlink), "How to install path repository packages: symlink or copy")
+	viper.BindPFlag("path-mode", installCmd.Flags().Lookup("path-mode"))
+	installCmd.Flags().BoolP("prefer-source", "", false, "Clone packages from their source repository instead of downloading dists")
+	viper.BindPFlag("prefer-source", installCmd.Flags().Lookup("prefer-source"))
+	installCmd.Flags().BoolP("require-checksums", "", false, "Fail when a locked package has no dist shasum to verify")
+	viper.BindPFlag("require-checksums", installCmd.Flags().Lookup("require-checksums"))
+	addAutoloadFlags(installCmd)
 }
 
 func runInstallCmd(cmd *cobra.Command, args []string) {
@@ -60,7 +97,53 @@ func runInstallCmd(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	err = pkg.Install(file, viper.GetBool("no-dev"), viper.GetBool("quiet"))
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		plan, err := pkg.NewPlan(file, !viper.GetBool("no-dev"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		format, _ := cmd.Flags().GetString("format")
+		switch format {
+		case "json":
+			planJSON, err := json.MarshalIndent(plan, "", "    ")
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println(string(planJSON))
+		case "text":
+			fmt.Print(plan)
+		default:
+			fmt.Printf("Unknown format %q\n", format)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var cache *pkg.Cache
+	if !viper.GetBool("no-cache") {
+		cache = openCache()
+	}
+
+	limits := pkg.ExtractLimits{
+		MaxEntries: viper.GetInt("extract.max-entries"),
+		MaxSize:    int64(viper.GetSizeInBytes("extract.max-size")),
+	}
+	err = pkg.Install(file, pkg.InstallOptions{
+		SkipDev:          viper.GetBool("no-dev"),
+		Quiet:            viper.GetBool("quiet"),
+		Jobs:             viper.GetInt("jobs"),
+		ExtractJobs:      viper.GetInt("extract-jobs"),
+		Download:         downloadOptions(file),
+		Limits:           limits,
+		Autoload:         autoloadOptions(),
+		Cache:            cache,
+		Offline:          viper.GetBool("offline"),
+		PathMode:         pkg.PathMode(viper.GetString("path-mode")),
+		PreferSource:     viper.GetBool("prefer-source"),
+		RequireChecksums: viper.GetBool("require-checksums"),
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
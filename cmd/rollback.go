@@ -0,0 +1,69 @@
+/*
+Copyright © 2020 John Laswell
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jlaswell/compote/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rollbackCmdShort = "Restore the vendor directory replaced by the last install"
+var rollbackCmdLong = rollbackCmdShort + `
+
+Each install keeps the packages it replaced in .compote_rollback
+within the project directory. Rollback puts them back, undoing
+the last install. Only one install can be undone.
+
+An install that was interrupted leaves .compote_backup behind
+instead. Rollback, or the next install, restores the vendor
+directory from it before anything else.
+
+Examples:
+  # Undo the last install for the project in the current directory.
+  compote rollback`
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: rollbackCmdShort,
+	Long:  rollbackCmdLong,
+	Run:   runRollbackCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollbackCmd(cmd *cobra.Command, args []string) {
+	file, err := pkg.LoadFile(viper.GetString("filepath"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = pkg.Rollback(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !viper.GetBool("quiet") {
+		fmt.Println("Restored the previous vendor directory")
+	}
+}
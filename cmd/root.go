@@ -18,7 +18,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/jlaswell/compote/pkg"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -54,8 +56,17 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default is $HOME/.compote.yaml)")
 	rootCmd.PersistentFlags().StringP("filepath", "f", ".", "Path to the directory or composer file to work from")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Do not write any output")
+	rootCmd.PersistentFlags().StringP("cache-dir", "", "", "Directory to cache downloads in (default is $XDG_CACHE_HOME/compote)")
 	viper.BindPFlag("filepath", rootCmd.PersistentFlags().Lookup("filepath"))
 	viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+
+	viper.SetDefault("download.retries", 3)
+	viper.SetDefault("download.backoff", pkg.DefaultBackoff)
+	viper.SetDefault("download.max-backoff", pkg.DefaultMaxBackoff)
+	viper.SetDefault("download.connect-timeout", 30*time.Second)
+	viper.SetDefault("extract.max-entries", pkg.DefaultMaxEntries)
+	viper.SetDefault("extract.max-size", pkg.DefaultMaxSize)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -76,6 +87,37 @@ func initConfig() {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// Keep stdout for command output, such as --format json.
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	}
+}
+
+// downloadOptions reads the download section of the config file along with
+// any auth.json credentials for file.
+func downloadOptions(file pkg.DependencyFile) pkg.DownloadOptions {
+	dirs := []string{}
+	if home, err := pkg.ComposerHome(); err == nil {
+		dirs = append(dirs, home)
+	}
+	auth, err := pkg.LoadAuth(append(dirs, file.Dirpath())...)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var rewrites []pkg.RewriteRule
+	if err := viper.UnmarshalKey("download.rewrites", &rewrites); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return pkg.DownloadOptions{
+		Retries:        viper.GetInt("download.retries"),
+		Backoff:        viper.GetDuration("download.backoff"),
+		MaxBackoff:     viper.GetDuration("download.max-backoff"),
+		Timeout:        viper.GetDuration("download.timeout"),
+		ConnectTimeout: viper.GetDuration("download.connect-timeout"),
+		Rewrites:       rewrites,
+		Auth:           auth,
 	}
 }
@@ -3,15 +3,15 @@ module github.com/jlaswell/compote
 go 1.13
 
 require (
+	github.com/dsnet/compress v0.0.1
 	github.com/go-openapi/strfmt v0.19.4 // indirect
 	github.com/jedib0t/go-pretty v4.3.0+incompatible
 	github.com/mattn/go-runewidth v0.0.7 // indirect
-	github.com/mholt/archiver v3.1.1+incompatible
-	github.com/mholt/archiver/v3 v3.3.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/pkg/errors v0.8.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.6.1
 	github.com/stretchr/testify v1.3.0
+	github.com/ulikunitz/xz v0.5.6
 )
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// archiveFormat identifies how a dist archive is packed.
+type archiveFormat string
+
+const (
+	formatZip    archiveFormat = "zip"
+	formatTar    archiveFormat = "tar"
+	formatTarGz  archiveFormat = "tar.gz"
+	formatTarBz2 archiveFormat = "tar.bz2"
+	formatTarXz  archiveFormat = "tar.xz"
+)
+
+var archiveMagic = []struct {
+	format archiveFormat
+	offset int
+	magic  []byte
+}{
+	{formatZip, 0, []byte("PK\x03\x04")},
+	{formatZip, 0, []byte("PK\x05\x06")},
+	{formatTarGz, 0, []byte("\x1f\x8b")},
+	{formatTarBz2, 0, []byte("BZh")},
+	{formatTarXz, 0, []byte("\xfd7zXZ\x00")},
+	{formatTar, 257, []byte("ustar")},
+}
+
+// sniffFormat identifies an archive from its leading bytes.
+func sniffFormat(header []byte) (archiveFormat, bool) {
+	for _, m := range archiveMagic {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format, true
+		}
+	}
+	return "", false
+}
+
+// formatFromDist guesses an archive format from the dist type and URL of a
+// package for archives that cannot be sniffed, such as pre-POSIX tarballs.
+func formatFromDist(p Package) (archiveFormat, bool) {
+	url := strings.ToLower(p.Distribution.URL)
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return formatTarGz, true
+	case strings.HasSuffix(url, ".tar.bz2"), strings.HasSuffix(url, ".tbz2"):
+		return formatTarBz2, true
+	case strings.HasSuffix(url, ".tar.xz"), strings.HasSuffix(url, ".txz"):
+		return formatTarXz, true
+	}
+	switch p.Distribution.Type {
+	case "zip":
+		return formatZip, true
+	case "tar":
+		return formatTar, true
+	case "gzip":
+		return formatTarGz, true
+	case "xz":
+		return formatTarXz, true
+	}
+	return "", false
+}
+
+// identifyArchive determines the format of the downloaded archive at path.
+func identifyArchive(path string, p Package) (archiveFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	format, ok := sniffFormat(header[:n])
+	if !ok {
+		format, ok = formatFromDist(p)
+	}
+	if !ok {
+		return "", fmt.Errorf("unable to determine the archive format of %s", p.Name)
+	}
+	return format, nil
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+func TestInstallArchiveFormats(t *testing.T) {
+	files := map[string]string{"composer.json": `{"name": "composer/semver"}`}
+	tests := map[string]struct {
+		archive  []byte
+		distType string
+		url      string
+	}{
+		"zip": {
+			archive:  zipArchive(t, "composer-semver-46d9139/", files),
+			distType: "zip",
+		},
+		"tar": {
+			archive:  tarArchive(t, "composer-semver-46d9139/", files, nil),
+			distType: "tar",
+		},
+		"tar.gz": {
+			archive: tarArchive(t, "composer-semver-46d9139/", files, func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			}),
+			distType: "tar",
+		},
+		"tar.bz2": {
+			archive: tarArchive(t, "composer-semver-46d9139/", files, func(w io.Writer) io.WriteCloser {
+				bw, err := bzip2.NewWriter(w, nil)
+				assert.Nil(t, err)
+				return bw
+			}),
+			distType: "tar",
+		},
+		"tar.xz": {
+			archive: tarArchive(t, "composer-semver-46d9139/", files, func(w io.Writer) io.WriteCloser {
+				xw, err := xz.NewWriter(w)
+				assert.Nil(t, err)
+				return xw
+			}),
+			distType: "tar",
+		},
+		"flat zip": {
+			archive:  zipArchive(t, "", files),
+			distType: "zip",
+		},
+		"flat tar.gz": {
+			archive: tarArchive(t, "", files, func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			}),
+			distType: "tar",
+		},
+		"tarball from a private repository without a dist type": {
+			archive: tarArchive(t, "semver/", files, func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			}),
+			url: "/semver-1.5.0.tgz",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			archive := tc.archive
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archive)
+			}))
+			defer server.Close()
+
+			file, cleanup := newTestLockfile(t, Package{
+				Name:         "composer/semver",
+				Distribution: Distribution{Type: tc.distType, URL: server.URL + tc.url},
+			})
+			defer cleanup()
+			assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+			assert.FileExists(t, filepath.Join(file.Dirpath(), "vendor", "composer", "semver", "composer.json"))
+		})
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := map[string]struct {
+		header []byte
+		format archiveFormat
+		ok     bool
+	}{
+		"zip":          {header: []byte("PK\x03\x04rest"), format: formatZip, ok: true},
+		"empty zip":    {header: []byte("PK\x05\x06"), format: formatZip, ok: true},
+		"gzip":         {header: []byte("\x1f\x8b\x08"), format: formatTarGz, ok: true},
+		"bzip2":        {header: []byte("BZh91AY"), format: formatTarBz2, ok: true},
+		"xz":           {header: []byte("\xfd7zXZ\x00\x00"), format: formatTarXz, ok: true},
+		"posix tar":    {header: append(make([]byte, 257), []byte("ustar\x0000")...), format: formatTar, ok: true},
+		"unrecognized": {header: []byte("<html>")},
+		"empty":        {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			format, ok := sniffFormat(tc.header)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.format, format)
+		})
+	}
+}
+
+// tarArchive builds an in-memory tarball with every file nested under root,
+// optionally compressed by the writer returned from compress.
+func tarArchive(t *testing.T, root string, files map[string]string, compress func(io.Writer) io.WriteCloser) []byte {
+	buf := new(bytes.Buffer)
+	var out io.Writer = buf
+	var compressor io.WriteCloser
+	if compress != nil {
+		compressor = compress(buf)
+		out = compressor
+	}
+	w := tar.NewWriter(out)
+	assert.Nil(t, w.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "46d9139"}}))
+	if root != "" {
+		assert.Nil(t, w.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: root, Mode: 0755}))
+	}
+	for name, contents := range files {
+		assert.Nil(t, w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: root + name, Mode: 0644, Size: int64(len(contents))}))
+		_, err := w.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	if compressor != nil {
+		assert.Nil(t, compressor.Close())
+	}
+	return buf.Bytes()
+}
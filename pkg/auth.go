@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Auth holds the credentials read from composer auth.json files, keyed by
+// host. Its String method never reveals the credentials themselves.
+type Auth struct {
+	HTTPBasic   map[string]BasicAuth   `json:"http-basic"`
+	Bearer      map[string]string      `json:"bearer"`
+	GitHubOAuth map[string]string      `json:"github-oauth"`
+	GitLabToken map[string]gitlabToken `json:"gitlab-token"`
+}
+
+// BasicAuth is a username and password used for HTTP basic authentication.
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// gitlabToken accepts both the plain string and the {"username", "token"}
+// forms composer allows for gitlab-token entries.
+type gitlabToken string
+
+func (t *gitlabToken) UnmarshalJSON(b []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "{") {
+		var token struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(b, &token); err != nil {
+			return err
+		}
+		*t = gitlabToken(token.Token)
+		return nil
+	}
+	var token string
+	if err := json.Unmarshal(b, &token); err != nil {
+		return err
+	}
+	*t = gitlabToken(token)
+	return nil
+}
+
+func (a *Auth) String() string {
+	hosts := len(a.HTTPBasic) + len(a.Bearer) + len(a.GitHubOAuth) + len(a.GitLabToken)
+	return fmt.Sprintf("auth with credentials for %d hosts", hosts)
+}
+
+// GoString keeps credentials out of %#v formatting.
+func (a *Auth) GoString() string {
+	return a.String()
+}
+
+// ComposerHome returns the directory composer keeps its global configuration
+// in, honoring COMPOSER_HOME.
+func ComposerHome() (string, error) {
+	if dir := os.Getenv("COMPOSER_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		if exists, _ := pathExists(filepath.Join(xdg, "composer")); exists {
+			return filepath.Join(xdg, "composer"), nil
+		}
+	}
+	if exists, _ := pathExists(filepath.Join(home, ".config", "composer")); exists {
+		return filepath.Join(home, ".config", "composer"), nil
+	}
+	return filepath.Join(home, ".composer"), nil
+}
+
+// LoadAuth reads auth.json from each directory in turn. Credentials for a
+// host in a later directory replace those from earlier ones, so the project
+// directory should be passed last. Missing files are ignored.
+func LoadAuth(dirs ...string) (*Auth, error) {
+	auth := &Auth{
+		HTTPBasic:   map[string]BasicAuth{},
+		Bearer:      map[string]string{},
+		GitHubOAuth: map[string]string{},
+		GitLabToken: map[string]gitlabToken{},
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, "auth.json")
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var file Auth
+		// Report only where parsing failed, as the contents are secret.
+		if err := json.Unmarshal(contents, &file); err != nil {
+			return nil, fmt.Errorf("unable to parse %s", path)
+		}
+		for host, c := range file.HTTPBasic {
+			auth.HTTPBasic[host] = c
+		}
+		for host, c := range file.Bearer {
+			auth.Bearer[host] = c
+		}
+		for host, c := range file.GitHubOAuth {
+			auth.GitHubOAuth[host] = c
+		}
+		for host, c := range file.GitLabToken {
+			auth.GitLabToken[host] = c
+		}
+	}
+	return auth, nil
+}
+
+// apply adds the credentials matching the request's host to req, reporting
+// whether any were found.
+func (a *Auth) apply(req *http.Request) bool {
+	for _, host := range []string{req.URL.Host, req.URL.Hostname()} {
+		if c, ok := a.HTTPBasic[host]; ok {
+			req.SetBasicAuth(c.Username, c.Password)
+			return true
+		}
+		if token, ok := a.Bearer[host]; ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return true
+		}
+		if token, ok := a.GitHubOAuth[githubDomain(host)]; ok {
+			req.Header.Set("Authorization", "token "+token)
+			return true
+		}
+		if token, ok := a.GitLabToken[host]; ok {
+			req.Header.Set("PRIVATE-TOKEN", string(token))
+			return true
+		}
+	}
+	return false
+}
+
+// githubDomain maps the hosts GitHub serves dists from to the domain their
+// oauth token is configured under.
+func githubDomain(host string) string {
+	switch host {
+	case "api.github.com", "codeload.github.com":
+		return "github.com"
+	}
+	return host
+}
+
+// authTransport applies credentials to every outgoing request, including
+// redirects, based on the host each request is sent to.
+type authTransport struct {
+	auth *Auth
+	next http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authed := req.Clone(req.Context())
+	if t.auth.apply(authed) {
+		return t.next.RoundTrip(authed)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_auth_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	home := filepath.Join(dir, "home")
+	project := filepath.Join(dir, "project")
+	assert.Nil(t, os.MkdirAll(home, 0755))
+	assert.Nil(t, os.MkdirAll(project, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(home, "auth.json"), []byte(`{
+		"http-basic": {"repo.example.org": {"username": "global", "password": "global-secret"}},
+		"github-oauth": {"github.com": "global-github-secret"},
+		"gitlab-token": {"gitlab.com": "gitlab-secret", "gitlab.example.org": {"username": "ci", "token": "ci-secret"}}
+	}`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(project, "auth.json"), []byte(`{
+		"http-basic": {"repo.example.org": {"username": "project", "password": "project-secret"}},
+		"bearer": {"satis.example.org": "bearer-secret"}
+	}`), 0600))
+
+	auth, err := LoadAuth(home, project, filepath.Join(dir, "missing"))
+	assert.Nil(t, err)
+	assert.Equal(t, BasicAuth{Username: "project", Password: "project-secret"}, auth.HTTPBasic["repo.example.org"])
+	assert.Equal(t, "bearer-secret", auth.Bearer["satis.example.org"])
+	assert.Equal(t, "global-github-secret", auth.GitHubOAuth["github.com"])
+	assert.Equal(t, gitlabToken("gitlab-secret"), auth.GitLabToken["gitlab.com"])
+	assert.Equal(t, gitlabToken("ci-secret"), auth.GitLabToken["gitlab.example.org"])
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		assert.NotContains(t, fmt.Sprintf(format, auth), "secret")
+	}
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(project, "auth.json"), []byte(`{"bearer": {"satis.example.org": 42}} "leaked-secret"`), 0600))
+	_, err = LoadAuth(project)
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestAuthenticatedDownloads(t *testing.T) {
+	auth := &Auth{
+		HTTPBasic:   map[string]BasicAuth{"basic.test": {Username: "user", Password: "basic-secret"}},
+		Bearer:      map[string]string{"bearer.test": "bearer-secret"},
+		GitHubOAuth: map[string]string{"github.com": "github-secret"},
+		GitLabToken: map[string]gitlabToken{"gitlab.test": "gitlab-secret"},
+	}
+	tests := map[string]struct {
+		host   string
+		header string
+		value  string
+	}{
+		"http-basic": {
+			host:   "basic.test",
+			header: "Authorization",
+			value:  "Basic dXNlcjpiYXNpYy1zZWNyZXQ=",
+		},
+		"bearer": {
+			host:   "bearer.test",
+			header: "Authorization",
+			value:  "Bearer bearer-secret",
+		},
+		"github-oauth for the api": {
+			host:   "api.github.com",
+			header: "Authorization",
+			value:  "token github-secret",
+		},
+		"gitlab-token": {
+			host:   "gitlab.test",
+			header: "PRIVATE-TOKEN",
+			value:  "gitlab-secret",
+		},
+		"unknown host": {
+			host:   "packagist.test",
+			header: "Authorization",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(tc.header)
+				w.Write([]byte("archive"))
+			}))
+			defer server.Close()
+
+			path := tempPath(t)
+			defer os.Remove(path)
+			d := routedDownloader(t, server, DownloadOptions{Auth: auth})
+			err := d.download(path, Package{Name: "acme/private", Distribution: Distribution{URL: "http://" + tc.host + "/acme/private.zip"}})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.value, got)
+		})
+	}
+}
+
+func TestAuthenticatedDownloadsFollowRedirectsPerHost(t *testing.T) {
+	auth := &Auth{Bearer: map[string]string{"private.test": "bearer-secret"}}
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Host+" "+r.Header.Get("Authorization"))
+		if r.Host == "private.test" {
+			http.Redirect(w, r, "http://storage.test/signed", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	path := tempPath(t)
+	defer os.Remove(path)
+	d := routedDownloader(t, server, DownloadOptions{Auth: auth})
+	err := d.download(path, Package{Name: "acme/private", Distribution: Distribution{URL: "http://private.test/acme/private.zip"}})
+	assert.Equal(t, []string{"private.test Bearer bearer-secret", "storage.test "}, authorizations)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "acme/private")
+	assert.Contains(t, err.Error(), "401")
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+// routedDownloader returns a downloader that sends every request to server,
+// whatever host it is addressed to.
+func routedDownloader(t *testing.T, server *httptest.Server, options DownloadOptions) *downloader {
+	target, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	d := newDownloader(options)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(target)
+	if at, ok := d.client.Transport.(*authTransport); ok {
+		at.next = transport
+	} else {
+		d.client.Transport = transport
+	}
+	d.sleep = func(time.Duration) {}
+	return d
+}
+
+func tempPath(t *testing.T) string {
+	f, err := ioutil.TempFile("", "compote_archive_")
+	assert.Nil(t, err)
+	f.Close()
+	return f.Name()
+}
@@ -0,0 +1,682 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ErrNotInstalled is returned by DumpAutoload when vendor does not record
+// which packages it contains.
+var ErrNotInstalled = errors.New("no installed packages to autoload, run install first")
+
+// AutoloadOptions controls how the autoloader is generated.
+type AutoloadOptions struct {
+	// Optimize scans every PSR-4 and PSR-0 directory into the classmap so
+	// that classes are found without searching the filesystem.
+	Optimize bool
+	// Authoritative implies Optimize and makes the classmap the only place
+	// classes are looked up. Classes missing from it are never searched for
+	// on the filesystem.
+	Authoritative bool
+}
+
+func (o AutoloadOptions) optimize() bool {
+	return o.Optimize || o.Authoritative
+}
+
+// autoloader collects the autoload mappings of installed packages and writes
+// the same vendor/autoload.php and vendor/composer files composer generates.
+type autoloader struct {
+	vendorDir  string
+	projectDir string
+	suffix     string
+	options    AutoloadOptions
+	// psr4 and psr0 map each prefix to absolute directories in the order
+	// they were added.
+	psr4 map[string][]string
+	psr0 map[string][]string
+	// classmapPaths lists the absolute files and directories to scan for
+	// classes, skipping those matched by the excludes patterns.
+	classmapPaths []classmapSource
+	excludes      []string
+	// files lists the files included on every request, in dependency order.
+	files []autoloadFile
+	// includePaths lists the absolute directories prepended to PHP's
+	// include_path.
+	includePaths []string
+}
+
+// autoloadFile is a file from an autoload files section along with the
+// identifier that keeps it from being included twice.
+type autoloadFile struct {
+	id   string
+	path string
+}
+
+// newAutoloader collects the mappings of packages installed in vendorDir and
+// of the root package in projectDir. As composer orders them, the root
+// package's mappings come first followed by the packages depending on the
+// rest, while files are included dependencies first and the root package's
+// last.
+func newAutoloader(vendorDir, projectDir string, packages []Package, root Package, options AutoloadOptions) (*autoloader, error) {
+	a := &autoloader{
+		vendorDir:  vendorDir,
+		projectDir: filepath.Clean(projectDir),
+		suffix:     autoloadSuffix(packages),
+		options:    options,
+		psr4:       make(map[string][]string),
+		psr0:       make(map[string][]string),
+	}
+	a.addIncludePaths(a.projectDir, root.IncludePath)
+	autoloads := make(map[string]Autoload, len(packages))
+	for _, p := range packages {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(p.Name))
+		autoload, err := targetDirAutoload(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		autoloads[p.Name] = autoload
+		a.addIncludePaths(dir, p.IncludePath)
+	}
+
+	sorted := sortPackages(packages)
+	a.add(a.projectDir, root.Autoload)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		a.add(filepath.Join(vendorDir, filepath.FromSlash(sorted[i].Name)), autoloads[sorted[i].Name])
+	}
+	for _, p := range sorted {
+		a.addFiles(p.Name, filepath.Join(vendorDir, filepath.FromSlash(p.Name)), autoloads[p.Name])
+	}
+	a.addFiles(root.Name, a.projectDir, root.Autoload)
+	return a, nil
+}
+
+// targetDirAutoload returns the autoload section of p with its paths made
+// relative to dir, the package directory p's target-dir is beneath. PSR-0
+// prefixes already name the target-dir, so like composer a path is only
+// looked for within the target-dir when it is missing from dir.
+func targetDirAutoload(dir string, p Package) (Autoload, error) {
+	autoload := p.Autoload
+	if p.TargetDir == "" {
+		return autoload, nil
+	}
+	if len(autoload.PSR4.Mappings()) > 0 {
+		return autoload, fmt.Errorf("PSR-4 autoloading is incompatible with the target-dir of %s", p.Name)
+	}
+	targetDir := strings.Trim(p.TargetDir, "/")
+	adjust := func(paths []string) []string {
+		var adjusted []string
+		for _, path := range paths {
+			if !exists(filepath.Join(dir, filepath.FromSlash(path))) {
+				path = targetDir + "/" + path
+			}
+			adjusted = append(adjusted, path)
+		}
+		return adjusted
+	}
+
+	psr0 := make(map[string][]string)
+	for prefix, paths := range autoload.PSR0.Mappings() {
+		psr0[prefix] = adjust(paths)
+	}
+	autoload.PSR0 = FlexPSR{Multiple: &psr0}
+	autoload.Classmap = adjust(autoload.Classmap)
+	autoload.ExcludeFromClassmap = adjust(autoload.ExcludeFromClassmap)
+	autoload.Files = adjust(autoload.Files)
+	return autoload, nil
+}
+
+// DumpAutoload regenerates the autoloader for the packages already installed
+// in the vendor directory next to file and for the root package. Without
+// withDev, packages-dev and the root package's autoload-dev section are left
+// out. Warnings about ambiguous classes are written to stderr unless quiet
+// is set.
+func DumpAutoload(file DependencyFile, withDev bool, options AutoloadOptions, quiet bool) error {
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	installed, ok, err := readInstalled(vendorDir)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrNotInstalled
+	}
+
+	packages := installed
+	if !withDev {
+		// Only the lockfile records which packages are for development.
+		locked := make(map[string]bool)
+		for _, p := range file.Dependencies(false) {
+			locked[p.Name] = true
+		}
+		packages = nil
+		for _, p := range installed {
+			if locked[p.Name] {
+				packages = append(packages, p)
+			}
+		}
+	}
+	root, err := file.RootPackage()
+	if err != nil {
+		return err
+	}
+	return writeAutoload(vendorDir, file.Dirpath(), packages, root.asPackage(withDev), options, quiet)
+}
+
+// writeAutoload generates the autoloader for packages installed in
+// vendorDir and the root package in projectDir. Warnings about ambiguous
+// classes are written to stderr unless quiet is set.
+func writeAutoload(vendorDir, projectDir string, packages []Package, root Package, options AutoloadOptions, quiet bool) error {
+	a, err := newAutoloader(vendorDir, projectDir, packages, root, options)
+	if err != nil {
+		return err
+	}
+	warnings, err := a.write()
+	if !quiet {
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning: "+warning)
+		}
+	}
+	return err
+}
+
+// autoloadSuffix names the autoloader class after the installed packages so
+// that identical installs generate identical files.
+func autoloadSuffix(packages []Package) string {
+	h := md5.New()
+	for _, p := range packages {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\n", p.Name, p.Version, packageReference(p))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// sortPackages orders packages so that dependencies come before the packages
+// requiring them, weighing each package by how much of the rest depends on
+// it as composer does. Packages of equal weight keep their order.
+func sortPackages(packages []Package) []Package {
+	var (
+		names     = make(map[string]bool)
+		usage     = make(map[string][]string)
+		computed  = make(map[string]int)
+		computing = make(map[string]bool)
+	)
+	for _, p := range packages {
+		names[strings.ToLower(p.Name)] = true
+	}
+	for _, p := range packages {
+		targets := make([]string, 0, len(p.Require))
+		for target := range p.Require {
+			targets = append(targets, strings.ToLower(target))
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			if names[target] {
+				usage[target] = append(usage[target], strings.ToLower(p.Name))
+			}
+		}
+	}
+
+	var importance func(name string) int
+	importance = func(name string) int {
+		if weight, ok := computed[name]; ok {
+			return weight
+		}
+		// A package on a circular dependency does not add to its own weight.
+		if computing[name] {
+			return 0
+		}
+		computing[name] = true
+		weight := 0
+		for _, user := range usage[name] {
+			weight -= 1 - importance(user)
+		}
+		delete(computing, name)
+		computed[name] = weight
+		return weight
+	}
+
+	sorted := append([]Package{}, packages...)
+	weights := make(map[string]int, len(sorted))
+	for _, p := range sorted {
+		weights[p.Name] = importance(strings.ToLower(p.Name))
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return weights[sorted[i].Name] < weights[sorted[j].Name]
+	})
+	return sorted
+}
+
+// fileIdentifier names an autoloaded file the same way composer does, so
+// that a file loaded by another autoloader is not included again.
+func fileIdentifier(name, path string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(name+":"+path)))
+}
+
+// add records the mappings of autoload, whose paths are relative to dir.
+func (a *autoloader) add(dir string, autoload Autoload) {
+	for prefix, paths := range autoload.PSR4.Mappings() {
+		for _, path := range paths {
+			a.psr4[prefix] = append(a.psr4[prefix], filepath.Join(dir, filepath.FromSlash(path)))
+		}
+	}
+	for prefix, paths := range autoload.PSR0.Mappings() {
+		for _, path := range paths {
+			a.psr0[prefix] = append(a.psr0[prefix], filepath.Join(dir, filepath.FromSlash(path)))
+		}
+	}
+	for _, path := range autoload.Classmap {
+		a.classmapPaths = append(a.classmapPaths, classmapSource{path: filepath.Join(dir, filepath.FromSlash(path))})
+	}
+	for _, glob := range autoload.ExcludeFromClassmap {
+		a.excludes = append(a.excludes, excludePattern(dir, glob))
+	}
+}
+
+// addFiles records the files of autoload for the package name, whose paths
+// are relative to dir.
+func (a *autoloader) addFiles(name, dir string, autoload Autoload) {
+	for _, path := range autoload.Files {
+		a.files = append(a.files, autoloadFile{
+			id:   fileIdentifier(name, path),
+			path: filepath.Join(dir, filepath.FromSlash(path)),
+		})
+	}
+}
+
+// addIncludePaths records include paths, which are relative to dir.
+func (a *autoloader) addIncludePaths(dir string, paths []string) {
+	for _, path := range paths {
+		a.includePaths = append(a.includePaths, filepath.Join(dir, filepath.FromSlash(strings.Trim(path, "/"))))
+	}
+}
+
+// write generates every autoload file, returning warnings about classes
+// that were declared more than once.
+func (a *autoloader) write() ([]string, error) {
+	exclude, err := excludeRegexp(a.excludes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude-from-classmap pattern: %w", err)
+	}
+	sources := a.classmapPaths
+	if a.options.optimize() {
+		sources = append(sources, a.psrSources()...)
+	}
+	classes, warnings, err := scanClassmap(sources, exclude)
+	if err != nil {
+		return warnings, err
+	}
+
+	composerDir := filepath.Join(a.vendorDir, "composer")
+	err = os.MkdirAll(composerDir, 0755)
+	if err != nil {
+		return warnings, err
+	}
+
+	files := map[string]string{
+		filepath.Join(a.vendorDir, "autoload.php"):            a.execute(autoloadTemplate),
+		filepath.Join(composerDir, "autoload_real.php"):       a.execute(autoloadRealTemplate),
+		filepath.Join(composerDir, "autoload_psr4.php"):       a.mapFile("autoload_psr4.php", a.psr4),
+		filepath.Join(composerDir, "autoload_namespaces.php"): a.mapFile("autoload_namespaces.php", a.psr0),
+		filepath.Join(composerDir, "autoload_classmap.php"):   a.classmapFile(classes),
+		filepath.Join(composerDir, "autoload_files.php"):      a.filesFile(),
+		filepath.Join(composerDir, "autoload_static.php"):     a.staticFile(classes),
+		filepath.Join(composerDir, "ClassLoader.php"):         classLoaderPHP,
+		filepath.Join(composerDir, "LICENSE"):                 composerLicense,
+	}
+	// include_paths.php only exists for packages that still rely on
+	// include_path, so drop any left from a previous install.
+	includePaths := filepath.Join(composerDir, "include_paths.php")
+	if len(a.includePaths) > 0 {
+		files[includePaths] = a.includePathsFile()
+	} else if err := os.Remove(includePaths); err != nil && !os.IsNotExist(err) {
+		return warnings, err
+	}
+	for path, contents := range files {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// psrSources lists the PSR-4 and PSR-0 directories to scan into the
+// classmap, longest prefix first as composer scans them. PSR-4 directories
+// come before PSR-0 ones for the same prefix.
+func (a *autoloader) psrSources() []classmapSource {
+	prefixes := make(map[string]bool)
+	for prefix := range a.psr4 {
+		prefixes[prefix] = true
+	}
+	for prefix := range a.psr0 {
+		prefixes[prefix] = true
+	}
+	sorted := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		sorted = append(sorted, prefix)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	var sources []classmapSource
+	for _, prefix := range sorted {
+		for _, mapping := range []struct {
+			standard string
+			dirs     []string
+		}{{standardPSR4, a.psr4[prefix]}, {standardPSR0, a.psr0[prefix]}} {
+			for _, dir := range mapping.dirs {
+				inVendor, _ := a.relativePath(dir)
+				sources = append(sources, classmapSource{path: dir, standard: mapping.standard, prefix: prefix, warn: !inVendor})
+			}
+		}
+	}
+	return sources
+}
+
+func (a *autoloader) execute(t *template.Template) string {
+	buf := new(bytes.Buffer)
+	// The templates are fixed and only reference fields that exist, so they
+	// cannot fail to execute.
+	t.Execute(buf, struct {
+		Suffix        string
+		Authoritative bool
+		IncludePaths  bool
+	}{a.suffix, a.options.Authoritative, len(a.includePaths) > 0})
+	return buf.String()
+}
+
+// mapFile renders a file returning prefixes mapped to lists of directories.
+// Longer prefixes sort first, as composer sorts them.
+func (a *autoloader) mapFile(name string, mappings map[string][]string) string {
+	prefixes := make([]string, 0, len(mappings))
+	for prefix := range mappings {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(prefixes)))
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(a.header(name))
+	buf.WriteString("return array(\n")
+	for _, prefix := range prefixes {
+		paths := make([]string, len(mappings[prefix]))
+		for i, path := range mappings[prefix] {
+			paths[i] = a.phpPath(path)
+		}
+		fmt.Fprintf(buf, "    %s => array(%s),\n", phpString(prefix), strings.Join(paths, ", "))
+	}
+	buf.WriteString(");\n")
+	return buf.String()
+}
+
+// classmapFile renders autoload_classmap.php, sorted by class name.
+func (a *autoloader) classmapFile(classes classmap) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(a.header("autoload_classmap.php"))
+	buf.WriteString("return array(\n")
+	for _, class := range classes.sortedClasses() {
+		fmt.Fprintf(buf, "    %s => %s,\n", phpString(class), a.phpPath(classes[class]))
+	}
+	buf.WriteString(");\n")
+	return buf.String()
+}
+
+// includePathsFile renders include_paths.php in the order paths are added
+// to include_path.
+func (a *autoloader) includePathsFile() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(a.header("include_paths.php"))
+	buf.WriteString("return array(\n")
+	for _, path := range a.includePaths {
+		fmt.Fprintf(buf, "    %s,\n", a.phpPath(path))
+	}
+	buf.WriteString(");\n")
+	return buf.String()
+}
+
+// filesFile renders autoload_files.php in the order files are included.
+func (a *autoloader) filesFile() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(a.header("autoload_files.php"))
+	buf.WriteString("return array(\n")
+	seen := make(map[string]bool)
+	for _, f := range a.files {
+		if !seen[f.id] {
+			seen[f.id] = true
+			fmt.Fprintf(buf, "    %s => %s,\n", phpString(f.id), a.phpPath(f.path))
+		}
+	}
+	buf.WriteString(");\n")
+	return buf.String()
+}
+
+// staticFile renders autoload_static.php, which hands the loader every
+// mapping at once rather than building them up from the other files.
+func (a *autoloader) staticFile(classes classmap) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<?php\n\n// autoload_static.php @generated by compote\n\nnamespace Composer\\Autoload;\n\nclass ComposerStaticInit%s\n{\n", a.suffix)
+	var properties []string
+	property := func(name string, write func()) {
+		properties = append(properties, name)
+		fmt.Fprintf(buf, "    public static $%s = array (\n", name)
+		write()
+		buf.WriteString("    );\n\n")
+	}
+	dirs := func(indent string, paths []string) {
+		for i, path := range paths {
+			fmt.Fprintf(buf, "%s%d => %s,\n", indent, i, a.staticPath(path))
+		}
+	}
+
+	property("files", func() {
+		seen := make(map[string]bool)
+		for _, f := range a.files {
+			if !seen[f.id] {
+				seen[f.id] = true
+				fmt.Fprintf(buf, "        %s => %s,\n", phpString(f.id), a.staticPath(f.path))
+			}
+		}
+	})
+
+	psr4, psr4Fallback := splitFallback(a.psr4)
+	if len(psr4) > 0 {
+		property("prefixLengthsPsr4", func() {
+			for _, group := range groupByFirstByte(psr4) {
+				fmt.Fprintf(buf, "        %s => \n        array (\n", phpString(group[0][:1]))
+				for _, prefix := range group {
+					fmt.Fprintf(buf, "            %s => %d,\n", phpString(prefix), len(prefix))
+				}
+				buf.WriteString("        ),\n")
+			}
+		})
+		property("prefixDirsPsr4", func() {
+			for _, prefix := range psr4 {
+				fmt.Fprintf(buf, "        %s => \n        array (\n", phpString(prefix))
+				dirs("            ", a.psr4[prefix])
+				buf.WriteString("        ),\n")
+			}
+		})
+	}
+	if len(psr4Fallback) > 0 {
+		property("fallbackDirsPsr4", func() { dirs("        ", psr4Fallback) })
+	}
+
+	psr0, psr0Fallback := splitFallback(a.psr0)
+	if len(psr0) > 0 {
+		property("prefixesPsr0", func() {
+			for _, group := range groupByFirstByte(psr0) {
+				fmt.Fprintf(buf, "        %s => \n        array (\n", phpString(group[0][:1]))
+				for _, prefix := range group {
+					fmt.Fprintf(buf, "            %s => \n            array (\n", phpString(prefix))
+					dirs("                ", a.psr0[prefix])
+					buf.WriteString("            ),\n")
+				}
+				buf.WriteString("        ),\n")
+			}
+		})
+	}
+	if len(psr0Fallback) > 0 {
+		property("fallbackDirsPsr0", func() { dirs("        ", psr0Fallback) })
+	}
+
+	property("classMap", func() {
+		for _, class := range classes.sortedClasses() {
+			fmt.Fprintf(buf, "        %s => %s,\n", phpString(class), a.staticPath(classes[class]))
+		}
+	})
+
+	fmt.Fprintf(buf, "    public static function getInitializer(ClassLoader $loader)\n    {\n        return \\Closure::bind(function () use ($loader) {\n")
+	// $files is read by autoload_real.php rather than handed to the loader.
+	for _, name := range properties[1:] {
+		fmt.Fprintf(buf, "            $loader->%s = ComposerStaticInit%s::$%s;\n", name, a.suffix, name)
+	}
+	buf.WriteString("\n        }, null, ClassLoader::class);\n    }\n}\n")
+	return buf.String()
+}
+
+// splitFallback returns the prefixes of mappings in the order composer writes
+// them, separately from the fallback directories of the empty prefix.
+func splitFallback(mappings map[string][]string) ([]string, []string) {
+	prefixes := make([]string, 0, len(mappings))
+	for prefix := range mappings {
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(prefixes)))
+	return prefixes, mappings[""]
+}
+
+// groupByFirstByte groups sorted prefixes by their first byte, as the class
+// loader looks them up.
+func groupByFirstByte(prefixes []string) [][]string {
+	var groups [][]string
+	for _, prefix := range prefixes {
+		if n := len(groups); n > 0 && groups[n-1][0][0] == prefix[0] {
+			groups[n-1] = append(groups[n-1], prefix)
+		} else {
+			groups = append(groups, []string{prefix})
+		}
+	}
+	return groups
+}
+
+// header opens a generated file that returns paths relative to $vendorDir
+// or $baseDir.
+func (a *autoloader) header(name string) string {
+	return "<?php\n\n// " + name + " @generated by compote\n\n" +
+		"$vendorDir = dirname(__DIR__);\n$baseDir = dirname($vendorDir);\n\n"
+}
+
+// phpPath renders path as a PHP expression relative to $vendorDir when it is
+// inside of the vendor directory, or to $baseDir otherwise.
+func (a *autoloader) phpPath(path string) string {
+	inVendor, rel := a.relativePath(path)
+	if inVendor {
+		return phpJoin("$vendorDir", rel)
+	}
+	return phpJoin("$baseDir", rel)
+}
+
+// staticPath renders path as a PHP expression relative to the
+// vendor/composer directory, as autoload_static.php refers to files.
+func (a *autoloader) staticPath(path string) string {
+	inVendor, rel := a.relativePath(path)
+	if inVendor {
+		return phpJoin("__DIR__ . '/..'", rel)
+	}
+	return phpJoin("__DIR__ . '/../..'", rel)
+}
+
+// relativePath returns path relative to the vendor directory when it is
+// inside of it, or to the project directory otherwise.
+func (a *autoloader) relativePath(path string) (bool, string) {
+	if rel, err := filepath.Rel(a.vendorDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true, rel
+	}
+	rel, err := filepath.Rel(a.projectDir, path)
+	if err != nil {
+		// Both directories are absolute, so this cannot happen in practice.
+		return false, path
+	}
+	return false, rel
+}
+
+func phpJoin(base, rel string) string {
+	if rel == "." {
+		return base
+	}
+	return base + " . " + phpString("/"+filepath.ToSlash(rel))
+}
+
+// phpString quotes s as a single quoted PHP string, as var_export does.
+func phpString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+var autoloadTemplate = template.Must(template.New("autoload.php").Parse(`<?php
+
+// autoload.php @generated by compote
+
+require_once __DIR__ . '/composer/autoload_real.php';
+
+return ComposerAutoloaderInit{{.Suffix}}::getLoader();
+`))
+
+var autoloadRealTemplate = template.Must(template.New("autoload_real.php").Parse(`<?php
+
+// autoload_real.php @generated by compote
+
+class ComposerAutoloaderInit{{.Suffix}}
+{
+    private static $loader;
+
+    public static function loadClassLoader($class)
+    {
+        if ('Composer\Autoload\ClassLoader' === $class) {
+            require __DIR__ . '/ClassLoader.php';
+        }
+    }
+
+    /**
+     * @return \Composer\Autoload\ClassLoader
+     */
+    public static function getLoader()
+    {
+        if (null !== self::$loader) {
+            return self::$loader;
+        }
+
+        spl_autoload_register(array('ComposerAutoloaderInit{{.Suffix}}', 'loadClassLoader'), true, true);
+        self::$loader = $loader = new \Composer\Autoload\ClassLoader(\dirname(__DIR__));
+        spl_autoload_unregister(array('ComposerAutoloaderInit{{.Suffix}}', 'loadClassLoader'));
+
+{{if .IncludePaths}}        $includePaths = require __DIR__ . '/include_paths.php';
+        $includePaths[] = get_include_path();
+        set_include_path(implode(PATH_SEPARATOR, $includePaths));
+
+{{end}}        require __DIR__ . '/autoload_static.php';
+        call_user_func(\Composer\Autoload\ComposerStaticInit{{.Suffix}}::getInitializer($loader));
+
+{{if .Authoritative}}        $loader->setClassMapAuthoritative(true);
+{{end}}        $loader->register(true);
+
+        $filesToLoad = \Composer\Autoload\ComposerStaticInit{{.Suffix}}::$files;
+        $requireFile = \Closure::bind(static function ($fileIdentifier, $file) {
+            if (empty($GLOBALS['__composer_autoload_files'][$fileIdentifier])) {
+                $GLOBALS['__composer_autoload_files'][$fileIdentifier] = true;
+
+                require $file;
+            }
+        }, null, null);
+        foreach ($filesToLoad as $fileIdentifier => $file) {
+            $requireFile($fileIdentifier, $file);
+        }
+
+        return $loader;
+    }
+}
+`))
@@ -0,0 +1,384 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAutoload(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	packages := []Package{
+		{
+			Name: "acme/single",
+			Autoload: Autoload{
+				PSR4: FlexPSR{Single: &map[string]string{`Acme\Single\`: "src/", `Acme\Single\Tests\`: "tests"}},
+				PSR0: FlexPSR{Single: &map[string]string{"Acme_Legacy_": "lib/"}},
+			},
+		},
+		{
+			Name: "acme/multiple",
+			Autoload: Autoload{
+				PSR4: FlexPSR{Multiple: &map[string][]string{`Acme\`: {"src", "generated/"}, "": {"fallback/"}}},
+			},
+		},
+		{
+			Name: "o'reilly/root",
+			Autoload: Autoload{
+				PSR4: FlexPSR{Single: &map[string]string{`OReilly\`: ""}},
+			},
+		},
+	}
+
+	assert.Nil(t, writeAutoload(vendorDir, file.Dirpath(), packages, Package{}, AutoloadOptions{}, true))
+	read := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, name))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+
+	assert.Equal(t, `<?php
+
+// autoload_psr4.php @generated by compote
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    'OReilly\\' => array($vendorDir . '/o\'reilly/root'),
+    'Acme\\Single\\Tests\\' => array($vendorDir . '/acme/single/tests'),
+    'Acme\\Single\\' => array($vendorDir . '/acme/single/src'),
+    'Acme\\' => array($vendorDir . '/acme/multiple/src', $vendorDir . '/acme/multiple/generated'),
+    '' => array($vendorDir . '/acme/multiple/fallback'),
+);
+`, read("composer/autoload_psr4.php"))
+	assert.Equal(t, `<?php
+
+// autoload_namespaces.php @generated by compote
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    'Acme_Legacy_' => array($vendorDir . '/acme/single/lib'),
+);
+`, read("composer/autoload_namespaces.php"))
+
+	suffix := autoloadSuffix(packages)
+	assert.Contains(t, read("autoload.php"), "return ComposerAutoloaderInit"+suffix+"::getLoader();")
+	assert.Contains(t, read("composer/autoload_real.php"), "class ComposerAutoloaderInit"+suffix+"\n")
+	assert.Contains(t, read("composer/ClassLoader.php"), "class ClassLoader")
+	assert.Contains(t, read("composer/ClassLoader.php"), "(c) Nils Adermann")
+	assert.Contains(t, read("composer/LICENSE"), "Copyright (c) Nils Adermann, Jordi Boggiano")
+	assert.NotEqual(t, suffix, autoloadSuffix(packages[:1]))
+}
+
+func TestAutoloaderPHPPath(t *testing.T) {
+	a := &autoloader{vendorDir: "/app/vendor", projectDir: "/app"}
+	tests := map[string]string{
+		"/app/vendor":              "$vendorDir",
+		"/app/vendor/acme/a/src":   "$vendorDir . '/acme/a/src'",
+		"/app/src":                 "$baseDir . '/src'",
+		"/app":                     "$baseDir",
+		"/app/vendor-bin/a":        "$baseDir . '/vendor-bin/a'",
+		"/packages/monorepo/a/src": "$baseDir . '/../packages/monorepo/a/src'",
+	}
+	for path, expected := range tests {
+		assert.Equal(t, expected, a.phpPath(filepath.FromSlash(path)), path)
+	}
+}
+
+func TestInstallWritesAutoload(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	for _, name := range []string{"autoload.php", "composer/autoload_real.php", "composer/autoload_psr4.php", "composer/autoload_namespaces.php", "composer/ClassLoader.php"} {
+		assert.FileExists(t, filepath.Join(file.Dirpath(), "vendor", filepath.FromSlash(name)))
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(file.Dirpath(), "vendor", "composer", "autoload_psr4.php"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(contents), "return array(\n);\n"))
+}
+
+func TestSortPackages(t *testing.T) {
+	requires := func(name string, targets ...string) Package {
+		p := Package{Name: name, Require: map[string]string{"php": ">=7.2"}}
+		for _, target := range targets {
+			p.Require[target] = "*"
+		}
+		return p
+	}
+	tests := map[string]struct {
+		packages []Package
+		order    []string
+	}{
+		"dependencies first": {
+			packages: []Package{requires("acme/app", "guzzlehttp/guzzle"), requires("guzzlehttp/guzzle", "guzzlehttp/psr7", "symfony/deprecation-contracts"), requires("guzzlehttp/psr7"), requires("symfony/deprecation-contracts")},
+			order:    []string{"guzzlehttp/psr7", "symfony/deprecation-contracts", "guzzlehttp/guzzle", "acme/app"},
+		},
+		"more dependents sort earlier": {
+			packages: []Package{requires("a/one", "c/shared"), requires("b/two", "c/shared", "d/single"), requires("c/shared"), requires("d/single")},
+			order:    []string{"c/shared", "d/single", "a/one", "b/two"},
+		},
+		"circular dependencies": {
+			packages: []Package{requires("a/one", "b/two"), requires("b/two", "a/one"), requires("c/three")},
+			order:    []string{"a/one", "b/two", "c/three"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var order []string
+			for _, p := range sortPackages(tc.packages) {
+				order = append(order, p.Name)
+			}
+			assert.Equal(t, tc.order, order)
+		})
+	}
+}
+
+func TestFileIdentifier(t *testing.T) {
+	// The identifiers composer itself generates for these files.
+	assert.Equal(t, "37a3dc5111fe8f707ab4c132ef1dbc62", fileIdentifier("guzzlehttp/guzzle", "src/functions_include.php"))
+	assert.Equal(t, "6e3fae29631ef280660b3cdad06f25a8", fileIdentifier("symfony/deprecation-contracts", "function.php"))
+}
+
+func TestWriteAutoloadStatic(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	writeFiles(t, vendorDir, map[string]string{"acme/legacy/lib/Legacy.php": "<?php class Legacy {}"})
+	packages := []Package{
+		{
+			Name:    "acme/app",
+			Require: map[string]string{"acme/helpers": "^1.0"},
+			Autoload: Autoload{
+				Files: []string{"bootstrap.php"},
+				PSR4:  FlexPSR{Multiple: &map[string][]string{`Acme\App\`: {"src/"}, `Acme\Api\`: {"api/"}, "": {"fallback/"}}},
+			},
+		},
+		{
+			Name: "acme/helpers",
+			Autoload: Autoload{
+				Files: []string{"src/functions.php"},
+				PSR0:  FlexPSR{Single: &map[string]string{"Helpers_": "lib/"}},
+			},
+		},
+		{
+			Name:     "acme/legacy",
+			Autoload: Autoload{Classmap: []string{"lib/"}},
+		},
+	}
+
+	assert.Nil(t, writeAutoload(vendorDir, file.Dirpath(), packages, Package{}, AutoloadOptions{}, true))
+	read := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, name))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+	assert.Equal(t, `<?php
+
+// autoload_files.php @generated by compote
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    '`+fileIdentifier("acme/helpers", "src/functions.php")+`' => $vendorDir . '/acme/helpers/src/functions.php',
+    '`+fileIdentifier("acme/app", "bootstrap.php")+`' => $vendorDir . '/acme/app/bootstrap.php',
+);
+`, read("composer/autoload_files.php"))
+
+	suffix := autoloadSuffix(packages)
+	assert.Equal(t, `<?php
+
+// autoload_static.php @generated by compote
+
+namespace Composer\Autoload;
+
+class ComposerStaticInit`+suffix+`
+{
+    public static $files = array (
+        '`+fileIdentifier("acme/helpers", "src/functions.php")+`' => __DIR__ . '/..' . '/acme/helpers/src/functions.php',
+        '`+fileIdentifier("acme/app", "bootstrap.php")+`' => __DIR__ . '/..' . '/acme/app/bootstrap.php',
+    );
+
+    public static $prefixLengthsPsr4 = array (
+        'A' => 
+        array (
+            'Acme\\App\\' => 9,
+            'Acme\\Api\\' => 9,
+        ),
+    );
+
+    public static $prefixDirsPsr4 = array (
+        'Acme\\App\\' => 
+        array (
+            0 => __DIR__ . '/..' . '/acme/app/src',
+        ),
+        'Acme\\Api\\' => 
+        array (
+            0 => __DIR__ . '/..' . '/acme/app/api',
+        ),
+    );
+
+    public static $fallbackDirsPsr4 = array (
+        0 => __DIR__ . '/..' . '/acme/app/fallback',
+    );
+
+    public static $prefixesPsr0 = array (
+        'H' => 
+        array (
+            'Helpers_' => 
+            array (
+                0 => __DIR__ . '/..' . '/acme/helpers/lib',
+            ),
+        ),
+    );
+
+    public static $classMap = array (
+        'Legacy' => __DIR__ . '/..' . '/acme/legacy/lib/Legacy.php',
+    );
+
+    public static function getInitializer(ClassLoader $loader)
+    {
+        return \Closure::bind(function () use ($loader) {
+            $loader->prefixLengthsPsr4 = ComposerStaticInit`+suffix+`::$prefixLengthsPsr4;
+            $loader->prefixDirsPsr4 = ComposerStaticInit`+suffix+`::$prefixDirsPsr4;
+            $loader->fallbackDirsPsr4 = ComposerStaticInit`+suffix+`::$fallbackDirsPsr4;
+            $loader->prefixesPsr0 = ComposerStaticInit`+suffix+`::$prefixesPsr0;
+            $loader->classMap = ComposerStaticInit`+suffix+`::$classMap;
+
+        }, null, ClassLoader::class);
+    }
+}
+`, read("composer/autoload_static.php"))
+	assert.Contains(t, read("composer/autoload_real.php"), `call_user_func(\Composer\Autoload\ComposerStaticInit`+suffix+`::getInitializer($loader));`)
+}
+
+func TestAutoloaderStaticPath(t *testing.T) {
+	a := &autoloader{vendorDir: "/app/vendor", projectDir: "/app"}
+	tests := map[string]string{
+		"/app/vendor":            "__DIR__ . '/..'",
+		"/app/vendor/acme/a/src": "__DIR__ . '/..' . '/acme/a/src'",
+		"/app/src":               "__DIR__ . '/../..' . '/src'",
+		"/app":                   "__DIR__ . '/../..'",
+	}
+	for path, expected := range tests {
+		assert.Equal(t, expected, a.staticPath(filepath.FromSlash(path)), path)
+	}
+}
+
+func TestDumpAutoload(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	assert.Equal(t, ErrNotInstalled, DumpAutoload(file, true, AutoloadOptions{}, true))
+
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	writeFiles(t, vendorDir, map[string]string{
+		"acme/tools/src/Tool.php":   `<?php namespace Acme; class Tool {}`,
+		"acme/debug/src/Dumper.php": `<?php namespace Acme\Debug; class Dumper {}`,
+	})
+	tools := Package{Name: "acme/tools", Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\`: "src/"}}}}
+	debug := Package{Name: "acme/debug", Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\Debug\`: "src/"}}}}
+	file.Packages, file.PackagesDev = []Package{tools}, []Package{debug}
+	assert.Nil(t, writeInstalled(vendorDir, []Package{tools, debug}))
+	classmap := func() string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", "autoload_classmap.php"))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+
+	assert.Nil(t, DumpAutoload(file, true, AutoloadOptions{Authoritative: true}, true))
+	assert.Contains(t, classmap(), `'Acme\\Tool' => $vendorDir . '/acme/tools/src/Tool.php',`)
+	assert.Contains(t, classmap(), `'Acme\\Debug\\Dumper' => $vendorDir . '/acme/debug/src/Dumper.php',`)
+	assert.FileExists(t, filepath.Join(vendorDir, "autoload.php"))
+
+	assert.Nil(t, DumpAutoload(file, false, AutoloadOptions{Authoritative: true}, true))
+	assert.Contains(t, classmap(), `'Acme\\Tool' => $vendorDir . '/acme/tools/src/Tool.php',`)
+	assert.NotContains(t, classmap(), "Dumper")
+}
+
+func TestWriteAutoloadTargetDir(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	projectDir := file.Dirpath()
+	vendorDir := filepath.Join(projectDir, "vendor")
+	writeFiles(t, vendorDir, map[string]string{
+		"legacy/swift/Swift/lib/Swift.php":            "<?php class Swift {}",
+		"legacy/swift/Swift/lib/swift_required.php":   "<?php",
+		"legacy/swift/Swift/classes/Swift_Mailer.php": "<?php class Swift_Mailer {}",
+		"legacy/swift/extra/Extra.php":                "<?php class Swift_Extra {}",
+	})
+	packages := []Package{
+		{
+			Name:      "legacy/swift",
+			TargetDir: "Swift",
+			Autoload: Autoload{
+				PSR0:     FlexPSR{Single: &map[string]string{"Swift_": "lib/"}},
+				Classmap: []string{"classes/", "extra/"},
+				Files:    []string{"lib/swift_required.php"},
+			},
+			IncludePath: []string{"lib/"},
+		},
+		{Name: "pear/console_table", IncludePath: []string{""}},
+	}
+	root := Package{Name: "acme/project", IncludePath: []string{"includes"}}
+
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages, root, AutoloadOptions{}, true))
+	read := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", name))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+	// Paths missing from the package directory are found in the target-dir.
+	assert.Contains(t, read("autoload_namespaces.php"), `'Swift_' => array($vendorDir . '/legacy/swift/Swift/lib'),`)
+	assert.Contains(t, read("autoload_files.php"), `'`+fileIdentifier("legacy/swift", "Swift/lib/swift_required.php")+`' => $vendorDir . '/legacy/swift/Swift/lib/swift_required.php',`)
+	assert.True(t, strings.HasSuffix(read("autoload_classmap.php"), `return array(
+    'Swift_Extra' => $vendorDir . '/legacy/swift/extra/Extra.php',
+    'Swift_Mailer' => $vendorDir . '/legacy/swift/Swift/classes/Swift_Mailer.php',
+);
+`))
+
+	// Include paths are relative to the package directory, not the
+	// target-dir, and the root package's come first.
+	assert.Equal(t, `<?php
+
+// include_paths.php @generated by compote
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    $baseDir . '/includes',
+    $vendorDir . '/legacy/swift/lib',
+    $vendorDir . '/pear/console_table',
+);
+`, read("include_paths.php"))
+	assert.Contains(t, read("autoload_real.php"), `
+        $includePaths = require __DIR__ . '/include_paths.php';
+        $includePaths[] = get_include_path();
+        set_include_path(implode(PATH_SEPARATOR, $includePaths));
+
+        require __DIR__ . '/autoload_static.php';
+`)
+
+	// Without include paths the file is removed and never required.
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages[:1], Package{}, AutoloadOptions{}, true))
+	assert.FileExists(t, filepath.Join(vendorDir, "composer", "include_paths.php"))
+	packages[0].IncludePath = nil
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages[:1], Package{}, AutoloadOptions{}, true))
+	assert.False(t, exists(filepath.Join(vendorDir, "composer", "include_paths.php")))
+	assert.NotContains(t, read("autoload_real.php"), "include_paths.php")
+
+	packages[0].Autoload.PSR4 = FlexPSR{Single: &map[string]string{`Swift\`: "src/"}}
+	err := writeAutoload(vendorDir, projectDir, packages[:1], Package{}, AutoloadOptions{}, true)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "target-dir of legacy/swift")
+	}
+}
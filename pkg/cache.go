@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache stores verified dist archives on disk so they can be reused across
+// installs. Entries are addressed by package name, dist reference and shasum.
+type Cache struct {
+	Dir string
+}
+
+// CacheEntry describes a single archive stored within a Cache.
+type CacheEntry struct {
+	Package  string
+	Key      string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/compote, falling back to the
+// platform's user cache directory when XDG_CACHE_HOME is unset.
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "compote"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "compote"), nil
+}
+
+// NewCache returns a Cache rooted at dir.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+func (c *Cache) filesDir() string {
+	return filepath.Join(c.Dir, "files")
+}
+
+// path returns where the archive for p lives within the cache. Packages
+// without a reference or shasum cannot be addressed and are never cached.
+func (c *Cache) path(p Package) (string, bool) {
+	if p.Distribution.Reference == "" && p.Distribution.Shasum == "" {
+		return "", false
+	}
+	return filepath.Join(c.filesDir(), filepath.FromSlash(p.Name), cacheKey(p)), true
+}
+
+// cacheKey derives the content address of a package's dist archive.
+func cacheKey(p Package) string {
+	key := sha256.Sum256([]byte(p.Name + "\x00" + p.Distribution.Reference + "\x00" + strings.ToLower(p.Distribution.Shasum)))
+	return hex.EncodeToString(key[:])
+}
+
+// Has reports whether an archive for p is stored in the cache.
+func (c *Cache) Has(p Package) bool {
+	path, ok := c.path(p)
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Fetch copies the cached archive for p to dst. It reports false when the
+// cache has no usable entry, removing entries that no longer match the
+// locked shasum.
+func (c *Cache) Fetch(p Package, dst string) (bool, error) {
+	path, ok := c.path(p)
+	if !ok {
+		return false, nil
+	}
+	in, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+	hash := sha1.New()
+	_, err = io.Copy(io.MultiWriter(out, hash), in)
+	if err != nil {
+		return false, err
+	}
+	if verifyChecksum(p, hex.EncodeToString(hash.Sum(nil))) != nil {
+		in.Close()
+		os.Remove(path)
+		return false, nil
+	}
+
+	// Track when an entry was last used so that prune removes stale entries.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return true, out.Close()
+}
+
+// Store adds a verified archive for p to the cache.
+func (c *Cache) Store(p Package, archive string) error {
+	path, ok := c.path(p)
+	if !ok {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Write to a temporary file first so concurrent installs never observe a
+	// partially written entry.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, in)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Entries lists every archive stored in the cache sorted by package name.
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	var entries []CacheEntry
+	root := c.filesDir()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp_") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, CacheEntry{
+			Package:  filepath.ToSlash(rel),
+			Key:      info.Name(),
+			Path:     path,
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Package == entries[j].Package {
+			return entries[i].LastUsed.After(entries[j].LastUsed)
+		}
+		return entries[i].Package < entries[j].Package
+	})
+	return entries, err
+}
+
+// Clear removes every archive from the cache.
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.filesDir())
+}
+
+// Prune removes archives that have not been used within the given duration
+// and returns the entries that were removed.
+func (c *Cache) Prune(olderThan time.Duration) ([]CacheEntry, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pruned []CacheEntry
+		cutoff = time.Now().Add(-olderThan)
+	)
+	for _, e := range entries {
+		if e.LastUsed.After(cutoff) {
+			continue
+		}
+		err = os.Remove(e.Path)
+		if err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, e)
+	}
+	return pruned, nil
+}
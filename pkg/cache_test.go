@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	semver := Package{
+		Name:         "composer/semver",
+		Distribution: Distribution{Reference: "46d9139568ccb8d9e7cdd4539cab7347568a5e2e"},
+	}
+	tests := map[string]struct {
+		stored  Package
+		fetched Package
+		hit     bool
+	}{
+		"stored package is fetched": {
+			stored:  semver,
+			fetched: semver,
+			hit:     true,
+		},
+		"different reference misses": {
+			stored:  semver,
+			fetched: Package{Name: "composer/semver", Distribution: Distribution{Reference: "ffffffffffffffffffffffffffffffffffffffff"}},
+		},
+		"different package misses": {
+			stored:  semver,
+			fetched: Package{Name: "composer/ca-bundle", Distribution: semver.Distribution},
+		},
+		"unaddressable package is never cached": {
+			stored:  Package{Name: "composer/semver"},
+			fetched: Package{Name: "composer/semver"},
+		},
+		"entry not matching the locked shasum is discarded": {
+			stored:  Package{Name: "composer/semver", Distribution: Distribution{Shasum: "a6e2b1e2bd31a7ac0d2eee1d9f4e4fd3bcd04b12"}},
+			fetched: Package{Name: "composer/semver", Distribution: Distribution{Shasum: "a6e2b1e2bd31a7ac0d2eee1d9f4e4fd3bcd04b12"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "compote_cache_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			cache := NewCache(filepath.Join(dir, "cache"))
+			archive := filepath.Join(dir, "archive.zip")
+			assert.Nil(t, ioutil.WriteFile(archive, []byte("archive contents"), 0644))
+
+			assert.Nil(t, cache.Store(tc.stored, archive))
+			dst := filepath.Join(dir, "fetched.zip")
+			hit, err := cache.Fetch(tc.fetched, dst)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.hit, hit)
+			assert.Equal(t, tc.hit, cache.Has(tc.fetched))
+			if tc.hit {
+				contents, err := ioutil.ReadFile(dst)
+				assert.Nil(t, err)
+				assert.Equal(t, "archive contents", string(contents))
+			}
+		})
+	}
+}
+
+func TestCacheEntriesPruneAndClear(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_cache_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache := NewCache(filepath.Join(dir, "cache"))
+
+	entries, err := cache.Entries()
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	archive := filepath.Join(dir, "archive.zip")
+	assert.Nil(t, ioutil.WriteFile(archive, []byte("archive contents"), 0644))
+	fresh := Package{Name: "doctrine/cache", Distribution: Distribution{Reference: "fresh"}}
+	stale := Package{Name: "composer/semver", Distribution: Distribution{Reference: "stale"}}
+	assert.Nil(t, cache.Store(fresh, archive))
+	assert.Nil(t, cache.Store(stale, archive))
+
+	stalePath, _ := cache.path(stale)
+	old := time.Now().Add(-48 * time.Hour)
+	assert.Nil(t, os.Chtimes(stalePath, old, old))
+
+	entries, err = cache.Entries()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "composer/semver", entries[0].Package)
+	assert.Equal(t, "doctrine/cache", entries[1].Package)
+	assert.Equal(t, int64(len("archive contents")), entries[0].Size)
+
+	pruned, err := cache.Prune(24 * time.Hour)
+	assert.Nil(t, err)
+	assert.Len(t, pruned, 1)
+	assert.Equal(t, "composer/semver", pruned[0].Package)
+	assert.False(t, cache.Has(stale))
+	assert.True(t, cache.Has(fresh))
+
+	assert.Nil(t, cache.Clear())
+	entries, err = cache.Entries()
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestInstallUsesCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_cache_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache := NewCache(dir)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(zipArchive(t, "composer-semver-46d9139/", map[string]string{"composer.json": "{}"}))
+	}))
+	defer server.Close()
+
+	file, cleanup := newTestLockfile(t, Package{
+		Name: "composer/semver",
+		Distribution: Distribution{
+			Type:      "zip",
+			URL:       server.URL + "/composer/semver.zip",
+			Reference: "46d9139568ccb8d9e7cdd4539cab7347568a5e2e",
+		},
+	})
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, os.RemoveAll(filepath.Join(file.Dirpath(), "vendor")))
+		assert.Nil(t, Install(file, InstallOptions{Quiet: true, Cache: cache}))
+		assert.FileExists(t, filepath.Join(file.Dirpath(), "vendor", "composer", "semver", "composer.json"))
+	}
+	assert.Equal(t, 1, requests)
+}
+
+func TestOfflineInstall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_cache_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache := NewCache(filepath.Join(dir, "cache"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected network request for %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	archive := filepath.Join(dir, "archive.zip")
+	assert.Nil(t, ioutil.WriteFile(archive, zipArchive(t, "package-abc123/", map[string]string{"composer.json": "{}"}), 0644))
+	cached := Package{Name: "composer/semver", Distribution: Distribution{Type: "zip", URL: server.URL + "/composer/semver.zip", Reference: "abc123"}}
+	local := Package{Name: "local/archive", Distribution: Distribution{Type: "zip", URL: "file://" + filepath.ToSlash(archive)}}
+	uncached := []Package{
+		{Name: "doctrine/cache", Distribution: Distribution{Type: "zip", URL: server.URL + "/doctrine/cache.zip", Reference: "def456"}},
+		{Name: "doctrine/dbal", Distribution: Distribution{Type: "zip", URL: server.URL + "/doctrine/dbal.zip", Reference: "789abc"}},
+	}
+
+	file, cleanup := newTestLockfile(t, append([]Package{cached, local}, uncached...)...)
+	defer cleanup()
+	err = Install(file, InstallOptions{Quiet: true, Cache: cache, Offline: true})
+	offlineErr, ok := err.(*OfflineError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"composer/semver", "doctrine/cache", "doctrine/dbal"}, offlineErr.Packages)
+
+	assert.Nil(t, cache.Store(cached, archive))
+	file.Packages = []Package{cached, local}
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true, Cache: cache, Offline: true}))
+	assert.FileExists(t, filepath.Join(file.Dirpath(), "vendor", "composer", "semver", "composer.json"))
+	assert.FileExists(t, filepath.Join(file.Dirpath(), "vendor", "local", "archive", "composer.json"))
+}
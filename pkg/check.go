@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AutoloadIssueType names the kind of problem an AutoloadIssue reports.
+type AutoloadIssueType string
+
+const (
+	// AutoloadIssueNamespace is a class whose namespace does not match the
+	// directory it is declared in.
+	AutoloadIssueNamespace AutoloadIssueType = "namespace"
+	// AutoloadIssueFilename is a class in the right directory whose name does
+	// not match its file.
+	AutoloadIssueFilename AutoloadIssueType = "filename"
+	// AutoloadIssueDuplicate is a class declared by more than one package.
+	AutoloadIssueDuplicate AutoloadIssueType = "duplicate"
+)
+
+// AutoloadIssue is a class that cannot be autoloaded the way its package
+// maps it. Paths are relative to the project directory.
+type AutoloadIssue struct {
+	Type    AutoloadIssueType `json:"type"`
+	Class   string            `json:"class"`
+	Package string            `json:"package"`
+	File    string            `json:"file"`
+	// Standard, Prefix and Path describe the mapping a mismatched class is
+	// found under. Expected is where that mapping would load the class from,
+	// and is empty when the class is outside of the prefix.
+	Standard string `json:"standard,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	// OtherPackage and OtherFile are where a duplicate class was first
+	// declared.
+	OtherPackage string `json:"other_package,omitempty"`
+	OtherFile    string `json:"other_file,omitempty"`
+}
+
+func (i AutoloadIssue) String() string {
+	if i.Type == AutoloadIssueDuplicate {
+		return fmt.Sprintf("%s: %s in %s is also declared by %s in %s", i.Package, i.Class, i.File, i.OtherPackage, i.OtherFile)
+	}
+	msg := fmt.Sprintf("%s: %s in %s has a %s that does not match %s mapping %q => %q", i.Package, i.Class, i.File, i.Type, i.Standard, i.Prefix, i.Path)
+	if i.Expected == "" {
+		return msg + ", which is for another namespace"
+	}
+	return msg + ", expected " + i.Expected
+}
+
+// AutoloadReport lists the issues CheckAutoload found.
+type AutoloadReport struct {
+	Issues []AutoloadIssue `json:"issues"`
+}
+
+// Empty reports whether every class can be autoloaded.
+func (r *AutoloadReport) Empty() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *AutoloadReport) String() string {
+	if r.Empty() {
+		return "No autoload issues found\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d autoload issues\n", len(r.Issues))
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&b, "  - %s\n", issue)
+	}
+	return b.String()
+}
+
+// psrMapping is a directory mapped to a prefix by a PSR-4 or PSR-0 section.
+type psrMapping struct {
+	standard string
+	prefix   string
+	dir      string
+}
+
+// checkedFile is a file found beneath the PSR mappings of a package.
+type checkedFile struct {
+	pkg      string
+	path     string
+	mappings []psrMapping
+}
+
+// CheckAutoload scans every PSR-4 and PSR-0 directory of the root package and
+// of the packages locked in file for classes that their mapping cannot load,
+// and for classes declared by more than one package. Packages are read from
+// the vendor directory, so packages that are not installed are skipped.
+// Without withDev, packages-dev and the root package's autoload-dev section
+// are left out.
+func CheckAutoload(file DependencyFile, withDev bool) (*AutoloadReport, error) {
+	projectDir := filepath.Clean(file.Dirpath())
+	vendorDir := filepath.Join(projectDir, "vendor")
+
+	root, err := file.RootPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		files   []checkedFile
+		indexes = make(map[string]int)
+	)
+	packages := append([]Package{root.asPackage(withDev)}, file.Dependencies(withDev)...)
+	for i, p := range packages {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(p.Name))
+		var patterns []string
+		if i == 0 {
+			dir = projectDir
+			// Packages in vendor are checked on their own.
+			patterns = append(patterns, regexp.QuoteMeta(filepath.ToSlash(vendorDir)))
+		}
+		autoload, err := targetDirAutoload(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, glob := range autoload.ExcludeFromClassmap {
+			patterns = append(patterns, excludePattern(dir, glob))
+		}
+		exclude, err := excludeRegexp(patterns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude-from-classmap pattern in %s: %w", p.Name, err)
+		}
+
+		for _, m := range packageMappings(dir, autoload) {
+			if !isDir(m.dir) {
+				continue
+			}
+			found, err := classmapFiles(m.dir, exclude)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range found {
+				key := p.Name + "\x00" + path
+				if _, ok := indexes[key]; !ok {
+					indexes[key] = len(files)
+					files = append(files, checkedFile{pkg: p.Name, path: path})
+				}
+				f := &files[indexes[key]]
+				f.mappings = append(f.mappings, m)
+			}
+		}
+	}
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	classes, err := readClasses(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	rel := func(path string) string {
+		if r, err := filepath.Rel(projectDir, path); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return filepath.ToSlash(path)
+	}
+	report := &AutoloadReport{Issues: []AutoloadIssue{}}
+	declared := make(map[string]checkedFile)
+	for i, f := range files {
+		for _, class := range classes[i] {
+			if first, ok := declared[class]; !ok {
+				declared[class] = f
+			} else if first.pkg != f.pkg {
+				report.Issues = append(report.Issues, AutoloadIssue{
+					Type:         AutoloadIssueDuplicate,
+					Class:        class,
+					Package:      f.pkg,
+					File:         rel(f.path),
+					OtherPackage: first.pkg,
+					OtherFile:    rel(first.path),
+				})
+			}
+
+			if issue, ok := checkClass(f, class); !ok {
+				issue.File = rel(f.path)
+				issue.Path = rel(issue.Path)
+				if issue.Expected != "" {
+					issue.Expected = rel(issue.Expected)
+				}
+				report.Issues = append(report.Issues, issue)
+			}
+		}
+	}
+	return report, nil
+}
+
+// packageMappings lists the PSR-4 and then PSR-0 directories of autoload,
+// whose paths are relative to dir, longest prefix first.
+func packageMappings(dir string, autoload Autoload) []psrMapping {
+	var mappings []psrMapping
+	for _, section := range []struct {
+		standard string
+		psr      FlexPSR
+	}{{standardPSR4, autoload.PSR4}, {standardPSR0, autoload.PSR0}} {
+		psr := section.psr.Mappings()
+		prefixes := make([]string, 0, len(psr))
+		for prefix := range psr {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(prefixes)))
+		for _, prefix := range prefixes {
+			for _, path := range psr[prefix] {
+				mappings = append(mappings, psrMapping{
+					standard: section.standard,
+					prefix:   prefix,
+					dir:      filepath.Join(dir, filepath.FromSlash(path)),
+				})
+			}
+		}
+	}
+	return mappings
+}
+
+// checkClass reports whether any mapping f was found under can load class
+// from it. When none can, the issue is described against the mapping
+// closest to the file.
+func checkClass(f checkedFile, class string) (AutoloadIssue, bool) {
+	closest := f.mappings[0]
+	for _, m := range f.mappings {
+		if compliant(m.standard, m.prefix, m.dir, f.path, class) {
+			return AutoloadIssue{}, true
+		}
+		if len(m.dir) > len(closest.dir) {
+			closest = m
+		}
+	}
+
+	issue := AutoloadIssue{
+		Type:     AutoloadIssueNamespace,
+		Class:    class,
+		Package:  f.pkg,
+		Standard: closest.standard,
+		Prefix:   closest.prefix,
+		Path:     closest.dir,
+	}
+	expected, ok := psrPath(closest.standard, closest.prefix, class)
+	if !ok {
+		return issue, false
+	}
+	issue.Expected = filepath.Join(closest.dir, filepath.FromSlash(expected)+filepath.Ext(f.path))
+	if actual, _ := filepath.Rel(closest.dir, f.path); path.Dir(filepath.ToSlash(actual)) == path.Dir(expected) {
+		issue.Type = AutoloadIssueFilename
+	}
+	return issue, false
+}
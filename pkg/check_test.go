@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAutoload(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	projectDir := file.Dirpath()
+	writeFiles(t, projectDir, map[string]string{
+		"app/Http/Controller.php":                 `<?php namespace App\Http; class Controller {}`,
+		"app/Http/Kernel.php":                     `<?php namespace App\Http; class HttpKernel {}`,
+		"app/Models/User.php":                     `<?php namespace App\Model; class User {}`,
+		"app/Support/helpers.php":                 "<?php function helper() {}",
+		"tests/ControllerTest.php":                `<?php namespace Tests; class ControllerTest {}`,
+		"tests/Fixtures/Broken.php":               `<?php namespace Tests\Other; class Broken {}`,
+		"vendor/acme/http/src/A.php":              `<?php namespace Acme\Http; class A {}`,
+		"vendor/acme/http/src/B.php":              `<?php namespace Acme\Client; class B {}`,
+		"vendor/acme/http/src/C/D.php":            `<?php namespace Acme\Http\C; class D {} class E {}`,
+		"vendor/acme/fork/src/A.php":              `<?php namespace Acme\Http; class A {}`,
+		"vendor/acme/pear/lib/Pear/Mail/Smtp.php": "<?php class Pear_Mail_Smtp {}",
+		"vendor/acme/pear/lib/Pear/Mail/Pop.php":  "<?php class Pear_Mail_Imap {}",
+		"vendor/acme/debug/src/Dumper.php":        `<?php namespace Debug; class Dump {}`,
+	})
+	file.root = &RootPackage{
+		Name: "acme/project",
+		Autoload: Autoload{
+			PSR4:                FlexPSR{Single: &map[string]string{`App\`: "app/", "": ""}},
+			ExcludeFromClassmap: []string{"tests/Fixtures/"},
+		},
+		AutoloadDev: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Tests\`: "tests/"}}},
+	}
+	file.Packages = []Package{
+		{Name: "acme/http", Autoload: Autoload{PSR4: FlexPSR{Multiple: &map[string][]string{`Acme\Http\`: {"src/"}}}}},
+		{Name: "acme/fork", Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\Http\`: "src/"}}}},
+		{Name: "acme/pear", Autoload: Autoload{PSR0: FlexPSR{Single: &map[string]string{"Pear_": "lib/"}}}},
+		{Name: "acme/missing", Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Missing\`: "src/"}}}},
+	}
+	file.PackagesDev = []Package{
+		{Name: "acme/debug", Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Debug\`: "src/"}}}},
+	}
+
+	report, err := CheckAutoload(file, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []AutoloadIssue{
+		{Type: AutoloadIssueFilename, Class: `App\Http\HttpKernel`, Package: "acme/project", File: "app/Http/Kernel.php", Standard: standardPSR4, Prefix: `App\`, Path: "app", Expected: "app/Http/HttpKernel.php"},
+		{Type: AutoloadIssueNamespace, Class: `App\Model\User`, Package: "acme/project", File: "app/Models/User.php", Standard: standardPSR4, Prefix: `App\`, Path: "app", Expected: "app/Model/User.php"},
+		{Type: AutoloadIssueNamespace, Class: `Acme\Client\B`, Package: "acme/http", File: "vendor/acme/http/src/B.php", Standard: standardPSR4, Prefix: `Acme\Http\`, Path: "vendor/acme/http/src"},
+		{Type: AutoloadIssueFilename, Class: `Acme\Http\C\E`, Package: "acme/http", File: "vendor/acme/http/src/C/D.php", Standard: standardPSR4, Prefix: `Acme\Http\`, Path: "vendor/acme/http/src", Expected: "vendor/acme/http/src/C/E.php"},
+		{Type: AutoloadIssueDuplicate, Class: `Acme\Http\A`, Package: "acme/fork", File: "vendor/acme/fork/src/A.php", OtherPackage: "acme/http", OtherFile: "vendor/acme/http/src/A.php"},
+		{Type: AutoloadIssueFilename, Class: "Pear_Mail_Imap", Package: "acme/pear", File: "vendor/acme/pear/lib/Pear/Mail/Pop.php", Standard: standardPSR0, Prefix: "Pear_", Path: "vendor/acme/pear/lib", Expected: "vendor/acme/pear/lib/Pear/Mail/Imap.php"},
+		{Type: AutoloadIssueFilename, Class: `Debug\Dump`, Package: "acme/debug", File: "vendor/acme/debug/src/Dumper.php", Standard: standardPSR4, Prefix: `Debug\`, Path: "vendor/acme/debug/src", Expected: "vendor/acme/debug/src/Dump.php"},
+	}, report.Issues)
+	assert.Equal(t, `Found 7 autoload issues
+  - acme/project: App\Http\HttpKernel in app/Http/Kernel.php has a filename that does not match psr-4 mapping "App\\" => "app", expected app/Http/HttpKernel.php
+  - acme/project: App\Model\User in app/Models/User.php has a namespace that does not match psr-4 mapping "App\\" => "app", expected app/Model/User.php
+  - acme/http: Acme\Client\B in vendor/acme/http/src/B.php has a namespace that does not match psr-4 mapping "Acme\\Http\\" => "vendor/acme/http/src", which is for another namespace
+  - acme/http: Acme\Http\C\E in vendor/acme/http/src/C/D.php has a filename that does not match psr-4 mapping "Acme\\Http\\" => "vendor/acme/http/src", expected vendor/acme/http/src/C/E.php
+  - acme/fork: Acme\Http\A in vendor/acme/fork/src/A.php is also declared by acme/http in vendor/acme/http/src/A.php
+  - acme/pear: Pear_Mail_Imap in vendor/acme/pear/lib/Pear/Mail/Pop.php has a filename that does not match psr-0 mapping "Pear_" => "vendor/acme/pear/lib", expected vendor/acme/pear/lib/Pear/Mail/Imap.php
+  - acme/debug: Debug\Dump in vendor/acme/debug/src/Dumper.php has a filename that does not match psr-4 mapping "Debug\\" => "vendor/acme/debug/src", expected vendor/acme/debug/src/Dump.php
+`, report.String())
+
+	report, err = CheckAutoload(file, false)
+	assert.Nil(t, err)
+	for _, issue := range report.Issues {
+		assert.NotEqual(t, "acme/debug", issue.Package)
+	}
+	// Without autoload-dev, tests/ is only covered by the fallback mapping.
+	assert.Len(t, report.Issues, 7)
+}
+
+func TestCheckAutoloadTargetDir(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	writeFiles(t, file.Dirpath(), map[string]string{
+		"vendor/legacy/swift/Swift/lib/Swift/Mailer.php": "<?php class Swift_Mailer {}",
+		"vendor/legacy/swift/Swift/lib/Swift/Pop.php":    "<?php class Swift_Imap {}",
+	})
+	file.Packages = []Package{{
+		Name:      "legacy/swift",
+		TargetDir: "Swift",
+		Autoload:  Autoload{PSR0: FlexPSR{Single: &map[string]string{"Swift_": "lib/"}}},
+	}}
+
+	// lib/ only exists within the target-dir.
+	report, err := CheckAutoload(file, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []AutoloadIssue{
+		{Type: AutoloadIssueFilename, Class: "Swift_Imap", Package: "legacy/swift", File: "vendor/legacy/swift/Swift/lib/Swift/Pop.php", Standard: standardPSR0, Prefix: "Swift_", Path: "vendor/legacy/swift/Swift/lib", Expected: "vendor/legacy/swift/Swift/lib/Swift/Imap.php"},
+	}, report.Issues)
+
+	file.Packages[0].Autoload.PSR4 = FlexPSR{Single: &map[string]string{`Swift\`: "src/"}}
+	_, err = CheckAutoload(file, true)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "target-dir of legacy/swift")
+	}
+}
+
+func TestAutoloadReport(t *testing.T) {
+	report := &AutoloadReport{Issues: []AutoloadIssue{}}
+	assert.True(t, report.Empty())
+	assert.Equal(t, "No autoload issues found\n", report.String())
+
+	report.Issues = append(report.Issues, AutoloadIssue{Type: AutoloadIssueDuplicate, Class: "Foo", Package: "acme/b", File: "vendor/acme/b/Foo.php", OtherPackage: "acme/a", OtherFile: "vendor/acme/a/Foo.php"})
+	assert.False(t, report.Empty())
+	reportJSON, err := json.Marshal(report)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"issues": [{"type": "duplicate", "class": "Foo", "package": "acme/b", "file": "vendor/acme/b/Foo.php", "other_package": "acme/a", "other_file": "vendor/acme/a/Foo.php"}]}`, string(reportJSON))
+
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	report, err = CheckAutoload(file, true)
+	assert.Nil(t, err)
+	assert.True(t, report.Empty())
+}
@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// classmapExtensions lists the files scanned for classes within classmap
+// directories. Files named directly in a classmap are scanned whatever their
+// extension.
+var classmapExtensions = map[string]bool{".php": true, ".inc": true, ".hh": true}
+
+// classmap maps fully qualified class names to the files declaring them.
+type classmap map[string]string
+
+// The autoloading standards a directory can be mapped with.
+const (
+	standardPSR4 = "psr-4"
+	standardPSR0 = "psr-0"
+)
+
+// classmapSource is a file or directory to scan for classes. Sources from a
+// PSR-4 or PSR-0 mapping only contribute the classes declared where that
+// standard expects them.
+type classmapSource struct {
+	path string
+	// standard and prefix are set for directories from a PSR-4 or PSR-0
+	// mapping.
+	standard string
+	prefix   string
+	// warn reports the classes a mapping skips, which is only worth doing
+	// for code outside of the vendor directory.
+	warn bool
+}
+
+// scanClassmap finds the classes declared in sources, scanning files in
+// parallel. Files matching exclude are skipped. When a class is declared
+// more than once the first file found wins and a warning is returned.
+func scanClassmap(sources []classmapSource, exclude *regexp.Regexp) (classmap, []string, error) {
+	var (
+		files   []string
+		origins []classmapSource
+	)
+	for _, source := range sources {
+		if source.standard != "" && !isDir(source.path) {
+			// Mapped directories that do not exist have no classes, as
+			// composer treats them.
+			continue
+		}
+		found, err := classmapFiles(source.path, exclude)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, found...)
+		for range found {
+			origins = append(origins, source)
+		}
+	}
+
+	classes, err := readClasses(files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		found    = make(classmap)
+		warnings []string
+	)
+	for i, file := range files {
+		for _, class := range classes[i] {
+			source := origins[i]
+			if source.standard != "" && !compliant(source.standard, source.prefix, source.path, file, class) {
+				if source.warn {
+					warnings = append(warnings, fmt.Sprintf("Class %s located in %s does not comply with %s autoloading standard. Skipping.", class, file, source.standard))
+				}
+				continue
+			}
+			if first, ok := found[class]; ok {
+				if first != file {
+					warnings = append(warnings, fmt.Sprintf("Ambiguous class resolution, %q was found in both %q and %q, the first will be used.", class, first, file))
+				}
+				continue
+			}
+			found[class] = file
+		}
+	}
+	return found, warnings, nil
+}
+
+// readClasses parses files in parallel, returning the classes each one
+// declares.
+func readClasses(files []string) ([][]string, error) {
+	var (
+		classes = make([][]string, len(files))
+		errs    = make([]error, len(files))
+		queue   = make(chan int)
+		wg      = new(sync.WaitGroup)
+	)
+	jobs := runtime.NumCPU()
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				src, err := ioutil.ReadFile(files[i])
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				classes[i] = phpClasses(src)
+			}
+		}()
+	}
+	for i := range files {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return classes, nil
+}
+
+// compliant reports whether class, declared in file beneath the directory
+// dir mapped to prefix, is where standard expects it to be.
+func compliant(standard, prefix, dir, file, class string) bool {
+	expected, ok := psrPath(standard, prefix, class)
+	if !ok {
+		return false
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if dot := strings.LastIndexByte(rel, '.'); dot >= 0 {
+		rel = rel[:dot]
+	}
+	return rel == expected
+}
+
+// psrPath returns the slash separated path, relative to a directory mapped
+// to prefix and without an extension, where standard expects class to be
+// declared. It reports false when class is outside of prefix.
+func psrPath(standard, prefix, class string) (string, bool) {
+	if !strings.HasPrefix(class, prefix) {
+		return "", false
+	}
+	if standard == standardPSR4 {
+		return strings.Replace(class[len(prefix):], `\`, "/", -1), true
+	}
+	// PSR-0 maps the whole class name, with underscores in the class but
+	// not its namespace separating directories.
+	namespace, name := "", class
+	if i := strings.LastIndexByte(class, '\\'); i >= 0 {
+		namespace, name = strings.Replace(class[:i+1], `\`, "/", -1), class[i+1:]
+	}
+	return namespace + strings.Replace(name, "_", "/", -1), true
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// classmapFiles lists the files to scan for a classmap path in lexical
+// order. A path that is itself a link to a directory, as path repositories
+// are installed, is followed.
+func classmapFiles(path string, exclude *regexp.Regexp) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan %s for classes: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	root, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.Walk(root, func(walked string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, walked)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(path, rel)
+		if exclude != nil && exclude.MatchString(filepath.ToSlash(file)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && classmapExtensions[filepath.Ext(file)] {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// excludePattern turns an exclude-from-classmap glob relative to dir into a
+// regular expression, treating ** as any number of directories and * as
+// part of a single path element as composer does. The pattern matches the
+// path and everything beneath it.
+func excludePattern(dir, glob string) string {
+	glob = strings.Trim(strings.Replace(glob, `\`, "/", -1), "/")
+	pattern := regexp.QuoteMeta(filepath.ToSlash(dir) + "/" + glob)
+	pattern = strings.Replace(pattern, `\*\*`, ".+?", -1)
+	return strings.Replace(pattern, `\*`, "[^/]+?", -1)
+}
+
+// excludeRegexp combines patterns from excludePattern, returning nil when
+// there are none.
+func excludeRegexp(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + strings.Join(patterns, "|") + ")(?:$|/)")
+}
+
+// sortedClasses returns the classes of m in the order composer writes them.
+func (m classmap) sortedClasses() []string {
+	classes := make([]string, 0, len(m))
+	for class := range m {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFiles creates files beneath dir from a map of slash separated paths to
+// contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+func TestScanClassmap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_classmap_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a/src/Foo.php":              "<?php namespace A; class Foo {}",
+		"a/src/Bar.inc":              "<?php namespace A; interface Bar {}",
+		"a/src/README.md":            "<?php class Readme {}",
+		"a/src/Tests/FooTest.php":    "<?php namespace A\\Tests; class FooTest {}",
+		"a/src/Fixtures/Fixture.php": "<?php class Fixture {}",
+		"a/legacy.txt":               "<?php class Legacy {}",
+		"b/lib/Foo.php":              "<?php namespace A; class Foo {}",
+		"b/lib/Baz.php":              "<?php namespace B; trait Baz {}",
+	})
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "b"), filepath.Join(dir, "linked")))
+
+	exclude, err := excludeRegexp([]string{
+		excludePattern(filepath.Join(dir, "a"), "/src/Tests/"),
+		excludePattern(filepath.Join(dir, "a"), "src/Fix*"),
+	})
+	assert.Nil(t, err)
+	classes, warnings, err := scanClassmap([]classmapSource{
+		{path: filepath.Join(dir, "a", "src")},
+		{path: filepath.Join(dir, "a", "legacy.txt")},
+		{path: filepath.Join(dir, "linked", "lib")},
+	}, exclude)
+	assert.Nil(t, err)
+	assert.Equal(t, classmap{
+		`A\Foo`:  filepath.Join(dir, "a", "src", "Foo.php"),
+		`A\Bar`:  filepath.Join(dir, "a", "src", "Bar.inc"),
+		"Legacy": filepath.Join(dir, "a", "legacy.txt"),
+		`B\Baz`:  filepath.Join(dir, "linked", "lib", "Baz.php"),
+	}, classes)
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0], `Ambiguous class resolution, "A\\Foo" was found in both`)
+	}
+
+	_, _, err = scanClassmap([]classmapSource{{path: filepath.Join(dir, "missing")}}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestExcludePattern(t *testing.T) {
+	tests := map[string]struct {
+		glob    string
+		matches map[string]bool
+	}{
+		"directory": {
+			glob: "/Tests/",
+			matches: map[string]bool{
+				"/vendor/a/Tests":             true,
+				"/vendor/a/Tests/FooTest.php": true,
+				"/vendor/a/TestsHelper.php":   false,
+				"/vendor/a/src/Tests":         false,
+			},
+		},
+		"single star": {
+			glob: "src/*/Fixtures",
+			matches: map[string]bool{
+				"/vendor/a/src/One/Fixtures/A.php":     true,
+				"/vendor/a/src/One/Two/Fixtures/A.php": false,
+			},
+		},
+		"double star": {
+			glob: "src/**/Fixtures",
+			matches: map[string]bool{
+				"/vendor/a/src/One/Fixtures/A.php":     true,
+				"/vendor/a/src/One/Two/Fixtures/A.php": true,
+				"/vendor/a/Fixtures/A.php":             false,
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			exclude, err := excludeRegexp([]string{excludePattern("/vendor/a", tc.glob)})
+			assert.Nil(t, err)
+			for path, matches := range tc.matches {
+				assert.Equal(t, matches, exclude.MatchString(path), path)
+			}
+		})
+	}
+}
+
+func TestWriteAutoloadClassmap(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	writeFiles(t, vendorDir, map[string]string{
+		"acme/tools/src/Tool.php":       "<?php namespace Acme; class Tool {}",
+		"acme/tools/src/Tests/Test.php": "<?php namespace Acme; class Test {}",
+	})
+
+	assert.Nil(t, writeAutoload(vendorDir, file.Dirpath(), []Package{{
+		Name:     "acme/tools",
+		Autoload: Autoload{Classmap: []string{"src/"}, ExcludeFromClassmap: []string{"/src/Tests/"}},
+	}}, Package{}, AutoloadOptions{}, true))
+	contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", "autoload_classmap.php"))
+	assert.Nil(t, err)
+	assert.Equal(t, `<?php
+
+// autoload_classmap.php @generated by compote
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    'Acme\\Tool' => $vendorDir . '/acme/tools/src/Tool.php',
+);
+`, string(contents))
+}
+
+func TestPSRPath(t *testing.T) {
+	tests := map[string]struct {
+		standard, prefix, class string
+		path                    string
+		ok                      bool
+	}{
+		"psr-4":              {standardPSR4, `Acme\`, `Acme\Http\Client`, "Http/Client", true},
+		"psr-4 fallback":     {standardPSR4, "", `Acme\Client`, "Acme/Client", true},
+		"psr-4 underscores":  {standardPSR4, `Acme\`, `Acme\Http_Client`, "Http_Client", true},
+		"psr-4 other prefix": {standardPSR4, `Acme\`, `Other\Client`, "", false},
+		"psr-0 namespaced":   {standardPSR0, `Acme\`, `Acme\Http\Old_Client`, "Acme/Http/Old/Client", true},
+		"psr-0 pear style":   {standardPSR0, "Twig_", "Twig_Node_Expression", "Twig/Node/Expression", true},
+		"psr-0 namespace _":  {standardPSR0, "", `Acme_Http\Client`, "Acme_Http/Client", true},
+		"psr-0 other prefix": {standardPSR0, "Twig_", "Swift_Mailer", "", false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, ok := psrPath(tc.standard, tc.prefix, tc.class)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestWriteAutoloadOptimized(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	projectDir := file.Dirpath()
+	vendorDir := filepath.Join(projectDir, "vendor")
+	writeFiles(t, vendorDir, map[string]string{
+		"acme/http/src/Client.php":             `<?php namespace Acme\Http; class Client {}`,
+		"acme/http/src/Middleware/Retry.php":   `<?php namespace Acme\Http\Middleware; class Retry {}`,
+		"acme/http/src/Misplaced.php":          `<?php namespace Acme\Http\Elsewhere; class Misplaced {}`,
+		"acme/http/src/Tests/ClientTest.php":   `<?php namespace Acme\Http\Tests; class ClientTest {}`,
+		"acme/legacy/lib/Legacy/Mail/Smtp.php": "<?php class Legacy_Mail_Smtp {}",
+	})
+	writeFiles(t, projectDir, map[string]string{
+		"app/Misplaced.php": `<?php namespace App\Models; class Misplaced {}`,
+	})
+	packages := []Package{
+		{
+			Name: "acme/http",
+			Autoload: Autoload{
+				PSR4:                FlexPSR{Single: &map[string]string{`Acme\Http\`: "src/"}},
+				ExcludeFromClassmap: []string{"/src/Tests/"},
+			},
+		},
+		{
+			Name:     "acme/legacy",
+			Autoload: Autoload{PSR0: FlexPSR{Single: &map[string]string{"Legacy_": "lib/", "Missing_": "missing/"}}},
+		},
+	}
+	read := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", name))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages, Package{}, AutoloadOptions{}, true))
+	assert.True(t, strings.HasSuffix(read("autoload_classmap.php"), "return array(\n);\n"))
+
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages, Package{}, AutoloadOptions{Optimize: true}, true))
+	assert.True(t, strings.HasSuffix(read("autoload_classmap.php"), `return array(
+    'Acme\\Http\\Client' => $vendorDir . '/acme/http/src/Client.php',
+    'Acme\\Http\\Middleware\\Retry' => $vendorDir . '/acme/http/src/Middleware/Retry.php',
+    'Legacy_Mail_Smtp' => $vendorDir . '/acme/legacy/lib/Legacy/Mail/Smtp.php',
+);
+`))
+	// The mappings stay in place for classes that are added later.
+	assert.Contains(t, read("autoload_psr4.php"), `'Acme\\Http\\' => array($vendorDir . '/acme/http/src'),`)
+	assert.NotContains(t, read("autoload_real.php"), "setClassMapAuthoritative")
+
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages, Package{}, AutoloadOptions{Authoritative: true}, true))
+	assert.Contains(t, read("autoload_classmap.php"), `'Acme\\Http\\Client' => $vendorDir . '/acme/http/src/Client.php',`)
+	assert.Contains(t, read("autoload_real.php"), "        $loader->setClassMapAuthoritative(true);\n        $loader->register(true);\n")
+
+	a, err := newAutoloader(vendorDir, projectDir, packages, Package{}, AutoloadOptions{Optimize: true})
+	assert.Nil(t, err)
+	a.add(projectDir, Autoload{PSR4: FlexPSR{Single: &map[string]string{`App\`: "app/"}}})
+	_, warnings, err := scanClassmap(a.psrSources(), nil)
+	assert.Nil(t, err)
+	// Only code outside of vendor is worth warning about.
+	assert.Equal(t, []string{
+		`Class App\Models\Misplaced located in ` + filepath.Join(projectDir, "app", "Misplaced.php") + " does not comply with psr-4 autoloading standard. Skipping.",
+	}, warnings)
+}
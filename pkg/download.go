@@ -0,0 +1,293 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBackoff is the delay before the first retry when
+	// DownloadOptions.Backoff is unset.
+	DefaultBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff caps the delay between retries when
+	// DownloadOptions.MaxBackoff is unset.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// DownloadOptions controls how dist archives are fetched over HTTP.
+type DownloadOptions struct {
+	// Retries is the number of additional attempts made after a download
+	// fails with a network error, a 5xx response or rate limiting.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles after every
+	// attempt, with jitter, up to MaxBackoff.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Timeout bounds a single download attempt. Zero means no limit.
+	Timeout time.Duration
+	// ConnectTimeout bounds establishing a connection. Zero means no limit.
+	ConnectTimeout time.Duration
+	// Rewrites are applied in order to every dist and mirror URL before it
+	// is downloaded. Only the first matching rule is applied.
+	Rewrites []RewriteRule
+	// Auth, when set, supplies credentials for the hosts dists are
+	// downloaded from.
+	Auth *Auth
+}
+
+// downloader fetches dist archives, retrying failed attempts and resuming
+// partial transfers where the server allows it.
+type downloader struct {
+	client  *http.Client
+	options DownloadOptions
+	sleep   func(time.Duration)
+}
+
+func newDownloader(options DownloadOptions) *downloader {
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxBackoff
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: options.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = options.ConnectTimeout
+	}
+	var rt http.RoundTripper = transport
+	if options.Auth != nil {
+		rt = &authTransport{auth: options.Auth, next: transport}
+	}
+	return &downloader{
+		client:  &http.Client{Transport: rt, Timeout: options.Timeout},
+		options: options,
+		sleep:   time.Sleep,
+	}
+}
+
+// retryableError marks a failed attempt that may succeed if tried again,
+// optionally after a delay requested by the server.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// partial tracks the bytes of an archive written so far across attempts.
+type partial struct {
+	out     *os.File
+	hash    hash.Hash
+	written int64
+}
+
+// reset discards everything written so far.
+func (t *partial) reset() error {
+	t.hash = sha1.New()
+	t.written = 0
+	_, err := t.out.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return t.out.Truncate(0)
+}
+
+// download writes the dist archive for a package to path, verifying it
+// against the locked shasum. Each candidate URL for the package is tried in
+// turn until one yields a verified archive.
+func (d *downloader) download(path string, p Package) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	t := &partial{out: out, hash: sha1.New()}
+
+	if local, ok := localArchive(p); ok {
+		err = copyLocal(t, local)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", p.Name, err)
+		}
+		err = verifyChecksum(p, hex.EncodeToString(t.hash.Sum(nil)))
+		if err != nil {
+			return err
+		}
+		return out.Close()
+	}
+
+	candidates := candidateURLs(p, d.options.Rewrites)
+	if len(candidates) == 0 {
+		return fmt.Errorf("unable to download %s: no dist url is locked", p.Name)
+	}
+	for _, src := range candidates {
+		err = t.reset()
+		if err != nil {
+			return err
+		}
+		err = d.fetch(t, src)
+		if err != nil {
+			err = fmt.Errorf("unable to download %s: %w", p.Name, err)
+			continue
+		}
+		err = verifyChecksum(p, hex.EncodeToString(t.hash.Sum(nil)))
+		if err == nil {
+			return out.Close()
+		}
+	}
+	return err
+}
+
+// fetch downloads src into t, retrying failed attempts.
+func (d *downloader) fetch(t *partial, src string) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = d.attempt(t, src)
+		retry, ok := err.(*retryableError)
+		if !ok || attempt >= d.options.Retries {
+			return err
+		}
+		d.sleep(d.delay(attempt, retry.retryAfter))
+	}
+}
+
+// attempt makes a single request for src, resuming from the bytes already
+// written when the server supports range requests.
+func (d *downloader) attempt(t *partial, src string) error {
+	req, err := http.NewRequest(http.MethodGet, src, nil)
+	if err != nil {
+		return err
+	}
+	if t.written > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", t.written))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && t.written > 0 && resumesAt(resp, t.written):
+	case resp.StatusCode == http.StatusPartialContent:
+		// The server resumed from somewhere else, so start over.
+		err = t.reset()
+		if err != nil {
+			return err
+		}
+		return &retryableError{err: fmt.Errorf("unable to resume: %s from %q", resp.Status, resp.Header.Get("Content-Range"))}
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range or this is the first attempt.
+		err = t.reset()
+		if err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		err = t.reset()
+		if err != nil {
+			return err
+		}
+		return &retryableError{err: fmt.Errorf("unable to resume: %s", resp.Status)}
+	case rateLimited(resp):
+		return &retryableError{err: fmt.Errorf("rate limited: %s", resp.Status), retryAfter: retryAfter(resp)}
+	case resp.StatusCode >= 500:
+		return &retryableError{err: fmt.Errorf("server error: %s", resp.Status), retryAfter: retryAfter(resp)}
+	default:
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	n, err := io.Copy(io.MultiWriter(t.out, t.hash), resp.Body)
+	t.written += n
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	return nil
+}
+
+// delay returns how long to wait before the next attempt, preferring any
+// delay requested by the server. Neither waits longer than MaxBackoff.
+func (d *downloader) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > d.options.MaxBackoff {
+		return d.options.MaxBackoff
+	} else if retryAfter > 0 {
+		return retryAfter
+	}
+	backoff := d.options.Backoff << uint(attempt)
+	if backoff <= 0 || backoff > d.options.MaxBackoff {
+		backoff = d.options.MaxBackoff
+	}
+	// Spread retries from concurrent downloads across the second half of the
+	// backoff window.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// resumesAt reports whether a partial response continues from offset.
+func resumesAt(resp *http.Response, offset int64) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset))
+}
+
+// rateLimited reports whether a response asks the client to slow down.
+// GitHub signals rate limiting with a 403 rather than a 429.
+func rateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header as either seconds or an HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// localArchive returns the filesystem path of a dist archive referenced by a
+// file:// URL.
+func localArchive(p Package) (string, bool) {
+	u, err := url.Parse(p.Distribution.URL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+func copyLocal(t *partial, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(io.MultiWriter(t.out, t.hash), in)
+	return err
+}
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadRetries(t *testing.T) {
+	archive := []byte("archive contents")
+	tests := map[string]struct {
+		failures []func(w http.ResponseWriter)
+		retries  int
+		attempts int
+		sleeps   []time.Duration
+		passes   bool
+	}{
+		"server errors are retried": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			},
+			retries:  3,
+			attempts: 3,
+			passes:   true,
+		},
+		"retries are exhausted": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+			},
+			retries:  2,
+			attempts: 3,
+		},
+		"missing archives are not retried": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			},
+			retries:  3,
+			attempts: 1,
+		},
+		"forbidden archives are not retried": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			},
+			retries:  3,
+			attempts: 1,
+		},
+		"too many requests honors retry-after": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "7")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			retries:  1,
+			attempts: 2,
+			sleeps:   []time.Duration{7 * time.Second},
+			passes:   true,
+		},
+		"github rate limiting is retried": {
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("Retry-After", "60")
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			retries:  1,
+			attempts: 2,
+			// The requested minute is capped at the default max backoff.
+			sleeps: []time.Duration{DefaultMaxBackoff},
+			passes: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= len(tc.failures) {
+					tc.failures[attempts-1](w)
+					return
+				}
+				w.Write(archive)
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "compote_download_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			var sleeps []time.Duration
+			d := newDownloader(DownloadOptions{Retries: tc.retries})
+			d.sleep = func(delay time.Duration) { sleeps = append(sleeps, delay) }
+			path := filepath.Join(dir, "archive")
+			err = d.download(path, Package{Name: "composer/semver", Distribution: Distribution{URL: server.URL}})
+
+			assert.Equal(t, tc.attempts, attempts)
+			assert.Len(t, sleeps, tc.attempts-1)
+			if tc.sleeps != nil {
+				assert.Equal(t, tc.sleeps, sleeps)
+			}
+			if !tc.passes {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), "composer/semver")
+				return
+			}
+			assert.Nil(t, err)
+			contents, err := ioutil.ReadFile(path)
+			assert.Nil(t, err)
+			assert.Equal(t, archive, contents)
+		})
+	}
+}
+
+func TestDownloadResumesPartialTransfers(t *testing.T) {
+	archive := []byte("the first half of the archive and then the second half")
+	sum := sha1.Sum(archive)
+	cut := 20
+	tests := map[string]struct {
+		supportsRange bool
+		misreported   bool
+		ranges        []string
+	}{
+		"interrupted download is resumed": {
+			supportsRange: true,
+			ranges:        []string{"", fmt.Sprintf("bytes=%d-", cut)},
+		},
+		"interrupted download restarts without range support": {
+			ranges: []string{"", fmt.Sprintf("bytes=%d-", cut)},
+		},
+		"misreported resume restarts the download": {
+			supportsRange: true,
+			misreported:   true,
+			ranges:        []string{"", fmt.Sprintf("bytes=%d-", cut), ""},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) == 1 {
+					// Promise the whole archive but hang up half way through.
+					w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+					w.Write(archive[:cut])
+					w.(http.Flusher).Flush()
+					conn, _, err := w.(http.Hijacker).Hijack()
+					assert.Nil(t, err)
+					conn.Close()
+					return
+				}
+				if tc.supportsRange && r.Header.Get("Range") != "" {
+					start := cut
+					if tc.misreported {
+						start = 0
+					}
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(archive)-1, len(archive)))
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write(archive[cut:])
+					return
+				}
+				w.Write(archive)
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "compote_download_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			d := newDownloader(DownloadOptions{Retries: 2})
+			d.sleep = func(time.Duration) {}
+			path := filepath.Join(dir, "archive")
+			err = d.download(path, Package{
+				Name:         "composer/semver",
+				Distribution: Distribution{URL: server.URL, Shasum: hex.EncodeToString(sum[:])},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.ranges, ranges)
+			contents, err := ioutil.ReadFile(path)
+			assert.Nil(t, err)
+			assert.Equal(t, archive, contents)
+		})
+	}
+}
+
+func TestDownloadDelay(t *testing.T) {
+	d := newDownloader(DownloadOptions{Backoff: time.Second, MaxBackoff: 5 * time.Second})
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		delay := d.delay(attempt, 0)
+		assert.True(t, delay >= max/2 && delay <= max, fmt.Sprintf("attempt %d waited %s", attempt, delay))
+	}
+	assert.Equal(t, 3*time.Second, d.delay(0, 3*time.Second))
+	assert.Equal(t, 5*time.Second, d.delay(0, time.Hour))
+}
@@ -0,0 +1,416 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// DefaultMaxEntries is the most entries a dist archive may hold when
+	// ExtractLimits.MaxEntries is unset.
+	DefaultMaxEntries = 100000
+	// DefaultMaxSize is the most bytes a dist archive may decompress to when
+	// ExtractLimits.MaxSize is unset.
+	DefaultMaxSize int64 = 1 << 30
+)
+
+// ExtractLimits bounds what a single dist archive may unpack, so that a
+// corrupt or hostile archive cannot fill the disk.
+type ExtractLimits struct {
+	// MaxEntries is the most files, directories and links an archive may
+	// hold. Defaults to DefaultMaxEntries when unset.
+	MaxEntries int
+	// MaxSize is the most bytes an archive may decompress to. Defaults to
+	// DefaultMaxSize when unset.
+	MaxSize int64
+}
+
+func (l ExtractLimits) maxEntries() int {
+	if l.MaxEntries <= 0 {
+		return DefaultMaxEntries
+	}
+	return l.MaxEntries
+}
+
+func (l ExtractLimits) maxSize() int64 {
+	if l.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return l.MaxSize
+}
+
+// UnsafeArchiveError reports an archive entry that extraction refused to
+// write.
+type UnsafeArchiveError struct {
+	Package string
+	Entry   string
+	Reason  string
+}
+
+func (e *UnsafeArchiveError) Error() string {
+	return fmt.Sprintf("refusing to extract %s: %s: %s", e.Package, e.Entry, e.Reason)
+}
+
+// archiveEntry is a single file, directory or link read from an archive.
+type archiveEntry struct {
+	name     string
+	mode     os.FileMode
+	linkname string
+	hardlink bool
+	open     func() (io.ReadCloser, error)
+}
+
+// walkArchive calls fn for every entry of the archive at path.
+func walkArchive(archive string, format archiveFormat, fn func(archiveEntry) error) error {
+	if format == formatZip {
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			err := fn(archiveEntry{name: f.Name, mode: zipMode(f.FileHeader), open: f.Open})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch format {
+	case formatTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case formatTarBz2:
+		r = bzip2.NewReader(f)
+	case formatTarXz:
+		r, err = xz.NewReader(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entry := archiveEntry{
+			name:     h.Name,
+			mode:     h.FileInfo().Mode(),
+			linkname: h.Linkname,
+			open:     func() (io.ReadCloser, error) { return ioutil.NopCloser(tr), nil },
+		}
+		switch h.Typeflag {
+		case tar.TypeXGlobalHeader:
+			// Tarballs from GitHub carry a global pax header before any files.
+			continue
+		case tar.TypeLink:
+			entry.hardlink = true
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// extractArchive unpacks archive into dest and returns the directory within
+// it that holds the package, as found by packageRoot. Entries that would land
+// outside of dest, links that resolve outside of the package and archives
+// that exceed limits are refused with an UnsafeArchiveError.
+func extractArchive(archive string, format archiveFormat, dest string, limits ExtractLimits) (string, error) {
+	var (
+		entries int
+		written int64
+		links   = make(map[string]string)
+		dirs    = make(map[string]os.FileMode)
+	)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", err
+	}
+	err := walkArchive(archive, format, func(e archiveEntry) error {
+		refuse := func(reason string, args ...interface{}) error {
+			return &UnsafeArchiveError{Entry: e.name, Reason: fmt.Sprintf(reason, args...)}
+		}
+
+		entries++
+		if entries > limits.maxEntries() {
+			return refuse("archive holds more than %d entries", limits.maxEntries())
+		}
+		name, ok := entryPath(e.name)
+		if !ok {
+			return refuse("path is absolute or outside of the package")
+		}
+		if name == "." {
+			return nil
+		}
+		// Writing beneath a link that was extracted earlier would follow it.
+		if link, ok := beneathLink(links, name); ok {
+			return refuse("path is beneath the link %s", link)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		if _, ok := links[name]; ok {
+			// A later entry replaces the link rather than following it.
+			os.Remove(target)
+			delete(links, name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		switch {
+		case e.hardlink:
+			linked, ok := entryPath(e.linkname)
+			if !ok {
+				return refuse("hard link to %s is outside of the package", e.linkname)
+			}
+			if _, ok := beneathLink(links, path.Join(linked, "x")); ok {
+				return refuse("hard link to %s is a link", e.linkname)
+			}
+			os.Remove(target)
+			return os.Link(filepath.Join(dest, filepath.FromSlash(linked)), target)
+		case e.mode&os.ModeSymlink != 0:
+			linkname := e.linkname
+			if linkname == "" {
+				// Zip archives store the link target as the entry's contents.
+				contents, err := readEntry(e, 4096)
+				if err != nil {
+					return err
+				}
+				linkname = string(contents)
+			}
+			// Links are checked against the package root once it is known, but
+			// may never point outside of dest in the meantime.
+			if !linkInside(name, linkname, "") {
+				return refuse("link to %s is outside of the package", linkname)
+			}
+			links[name] = linkname
+			os.Remove(target)
+			return os.Symlink(linkname, target)
+		case e.mode.IsDir():
+			// Directory modes are applied once every entry is written so that
+			// a read-only directory can still be filled. The owner always
+			// keeps full access so that later installs can replace it.
+			dirs[target] = e.mode.Perm() | 0700
+			return os.MkdirAll(target, 0755)
+		case e.mode.IsRegular():
+			n, err := writeEntry(e, target, limits.maxSize()-written)
+			written += n
+			if err == errEntryTooLarge {
+				return refuse("archive decompresses to more than %d bytes", limits.maxSize())
+			}
+			return err
+		default:
+			return refuse("unsupported entry type %s", e.mode.Type())
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	for dir, mode := range dirs {
+		if err := os.Chmod(dir, mode); err != nil {
+			return "", err
+		}
+	}
+
+	root, err := packageRoot(dest)
+	if err != nil {
+		return "", err
+	}
+	for name, linkname := range links {
+		if within(name, root) && !linkInside(name, linkname, root) {
+			return "", &UnsafeArchiveError{Entry: name, Reason: fmt.Sprintf("link to %s is outside of the package", linkname)}
+		}
+	}
+	if root == "" {
+		// dest is the package itself, so the metadata packageRoot ignored
+		// would otherwise be installed with it.
+		for _, name := range archiveMetadata {
+			if err := os.RemoveAll(filepath.Join(dest, name)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return filepath.Join(dest, filepath.FromSlash(root)), nil
+}
+
+// packageRoot returns the top-level directory of an extracted archive when it
+// is the only entry, as it is for archives from GitHub and most other hosts.
+// Archives with files at the top level or several top-level directories hold
+// the package itself, so an empty root is returned for them. Metadata added
+// by macOS archivers is ignored.
+func packageRoot(dest string) (string, error) {
+	infos, err := ioutil.ReadDir(dest)
+	if err != nil {
+		return "", err
+	}
+	var top []os.FileInfo
+	for _, info := range infos {
+		if !isArchiveMetadata(info.Name()) {
+			top = append(top, info)
+		}
+	}
+	switch {
+	case len(top) == 0:
+		return "", errors.New("archive is empty")
+	case len(top) == 1 && top[0].IsDir():
+		return top[0].Name(), nil
+	}
+	return "", nil
+}
+
+// archiveMetadata lists the top-level entries macOS archivers add beside the
+// package.
+var archiveMetadata = []string{"__MACOSX", ".DS_Store"}
+
+func isArchiveMetadata(name string) bool {
+	for _, metadata := range archiveMetadata {
+		if name == metadata {
+			return true
+		}
+	}
+	return false
+}
+
+// zipMode returns the mode of a zip entry. Only archives created on Unix
+// record permissions and symlinks in their external attributes. Entries from
+// other systems, or Unix entries without permissions, get the usual defaults
+// rather than the world writable modes zip reports for them.
+func zipMode(h zip.FileHeader) os.FileMode {
+	const (
+		creatorUnix   = 3
+		creatorMacOSX = 19
+	)
+	mode := h.Mode()
+	creator := h.CreatorVersion >> 8
+	if (creator == creatorUnix || creator == creatorMacOSX) && mode.Perm() != 0 {
+		return mode
+	}
+	if mode.IsDir() {
+		return os.ModeDir | 0755
+	}
+	return mode.Type() | 0644
+}
+
+var errEntryTooLarge = errors.New("entry too large")
+
+// beneathLink returns the link among links that name is or sits beneath.
+func beneathLink(links map[string]string, name string) (string, bool) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := links[dir]; ok {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// writeEntry copies the contents of e to target, writing at most max bytes.
+func writeEntry(e archiveEntry, target string, max int64) (int64, error) {
+	r, err := e.open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	// Replace rather than write through whatever is already at target.
+	os.Remove(target)
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.mode.Perm())
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	// Set the mode explicitly since the one given to OpenFile is reduced by
+	// the umask, which would drop executable bits for other users. Setuid,
+	// setgid and sticky bits are never restored.
+	if err := out.Chmod(e.mode.Perm()); err != nil {
+		return 0, err
+	}
+	n, err := io.CopyN(out, r, max+1)
+	if err == io.EOF {
+		err = nil
+	} else if err == nil {
+		err = errEntryTooLarge
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, out.Close()
+}
+
+// readEntry returns the contents of a small entry, failing beyond max bytes.
+func readEntry(e archiveEntry, max int64) ([]byte, error) {
+	r, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	contents, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err == nil && int64(len(contents)) > max {
+		err = errEntryTooLarge
+	}
+	return contents, err
+}
+
+// entryPath cleans an archive entry name into a slash separated path
+// relative to the extraction root, reporting false when the name is absolute
+// or climbs out of the root.
+func entryPath(name string) (string, bool) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || filepath.VolumeName(name) != "" {
+		return "", false
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// within reports whether the entry path name is root or lies beneath it. Every
+// path is within an empty root.
+func within(name, root string) bool {
+	return root == "" || name == root || strings.HasPrefix(name, root+"/")
+}
+
+// linkInside reports whether a symlink at name pointing to linkname stays
+// within root. Only leading ".." elements are allowed so that the check never
+// depends on where other links in the archive point.
+func linkInside(name, linkname, root string) bool {
+	if linkname == "" || strings.HasPrefix(linkname, "/") || filepath.VolumeName(linkname) != "" {
+		return false
+	}
+	descended := false
+	for _, elem := range strings.Split(linkname, "/") {
+		if elem == ".." && descended {
+			return false
+		}
+		if elem != ".." && elem != "." && elem != "" {
+			descended = true
+		}
+	}
+	resolved, ok := entryPath(path.Join(path.Dir(name), linkname))
+	return ok && within(resolved, root)
+}
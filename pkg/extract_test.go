@@ -0,0 +1,356 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEntry describes a single entry written by zipEntries or tarEntries.
+type testEntry struct {
+	name     string
+	mode     os.FileMode
+	contents string
+	linkname string
+	hardlink bool
+}
+
+// zipEntries builds a zip archive holding entries in order. Symlinks store
+// their target as their contents, as zip tools do.
+func zipEntries(t *testing.T, entries ...testEntry) []byte {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(e.mode)
+		f, err := w.CreateHeader(h)
+		assert.Nil(t, err)
+		contents := e.contents
+		if e.mode&os.ModeSymlink != 0 {
+			contents = e.linkname
+		}
+		_, err = f.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+// tarEntries builds an uncompressed tarball holding entries in order.
+func tarEntries(t *testing.T, entries ...testEntry) []byte {
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Linkname: e.linkname, Typeflag: tar.TypeReg, Size: int64(len(e.contents))}
+		switch {
+		case e.hardlink:
+			h.Typeflag, h.Size = tar.TypeLink, 0
+		case e.mode&os.ModeSymlink != 0:
+			h.Typeflag, h.Size = tar.TypeSymlink, 0
+		case e.mode.IsDir():
+			h.Typeflag, h.Size = tar.TypeDir, 0
+		}
+		assert.Nil(t, w.WriteHeader(h))
+		_, err := w.Write([]byte(e.contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	file := func(name, contents string) testEntry {
+		return testEntry{name: name, mode: 0644, contents: contents}
+	}
+	link := func(name, linkname string) testEntry {
+		return testEntry{name: name, mode: os.ModeSymlink | 0777, linkname: linkname}
+	}
+	tests := map[string]struct {
+		format  archiveFormat
+		entries []testEntry
+		limits  ExtractLimits
+		refused string
+	}{
+		"regular package": {
+			entries: []testEntry{{name: "root/", mode: os.ModeDir | 0755}, file("root/src/A.php", "<?php"), link("root/bin/a", "../src/A.php")},
+		},
+		"absolute path": {
+			entries: []testEntry{file("/tmp/evil", "evil")},
+			refused: "/tmp/evil",
+		},
+		"parent traversal": {
+			entries: []testEntry{file("root/../../evil", "evil")},
+			refused: "root/../../evil",
+		},
+		"link outside of the archive": {
+			entries: []testEntry{link("root/etc", "../../etc")},
+			refused: "root/etc",
+		},
+		"absolute link": {
+			entries: []testEntry{link("root/etc", "/etc")},
+			refused: "root/etc",
+		},
+		"link out of the package root": {
+			entries: []testEntry{file("root/a", "a"), link("root/secret", "../secret")},
+			refused: "root/secret",
+		},
+		"link within a flat archive": {
+			entries: []testEntry{file("src/A.php", "<?php"), link("bin/a", "../src/A.php")},
+		},
+		"link climbing after descending": {
+			entries: []testEntry{link("root/self", "."), link("root/up", "self/../..")},
+			refused: "root/up",
+		},
+		"path beneath a link": {
+			entries: []testEntry{link("root/src", "lib"), file("root/src/evil", "evil")},
+			refused: "root/src/evil",
+		},
+		"too many entries": {
+			entries: []testEntry{file("root/a", "a"), file("root/b", "b"), file("root/c", "c")},
+			limits:  ExtractLimits{MaxEntries: 2},
+			refused: "root/c",
+		},
+		"too large": {
+			entries: []testEntry{file("root/a", "abc"), file("root/b", "defg")},
+			limits:  ExtractLimits{MaxSize: 6},
+			refused: "root/b",
+		},
+		"tar hard link within the package": {
+			format:  formatTar,
+			entries: []testEntry{file("root/a", "a"), {name: "root/b", hardlink: true, linkname: "root/a"}},
+		},
+		"tar hard link outside of the package": {
+			format:  formatTar,
+			entries: []testEntry{{name: "root/passwd", hardlink: true, linkname: "../../etc/passwd"}},
+			refused: "root/passwd",
+		},
+		"tar link outside of the archive": {
+			format:  formatTar,
+			entries: []testEntry{link("root/etc", "../../etc")},
+			refused: "root/etc",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "compote_extract_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			format, contents := tc.format, zipEntries(t, tc.entries...)
+			if format == formatTar {
+				contents = tarEntries(t, tc.entries...)
+			} else {
+				format = formatZip
+			}
+			archive := filepath.Join(dir, "archive")
+			assert.Nil(t, ioutil.WriteFile(archive, contents, 0644))
+			dest := filepath.Join(dir, "dest")
+
+			_, err = extractArchive(archive, format, dest, tc.limits)
+			if tc.refused == "" {
+				assert.Nil(t, err)
+				return
+			}
+			var unsafe *UnsafeArchiveError
+			if assert.True(t, errors.As(err, &unsafe), "expected an UnsafeArchiveError, got %v", err) {
+				assert.Equal(t, tc.refused, unsafe.Entry)
+			}
+			assert.False(t, exists(filepath.Join(dir, "evil")))
+		})
+	}
+}
+
+func TestInstallRefusesUnsafeArchives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipEntries(t,
+			testEntry{name: "root/", mode: os.ModeDir | 0755},
+			testEntry{name: "root/../../../evil", mode: 0644, contents: "evil"},
+		))
+	}))
+	defer server.Close()
+	file, cleanup := newTestLockfile(t, Package{Name: "hostile/package", Distribution: Distribution{Type: "zip", URL: server.URL}})
+	defer cleanup()
+
+	err := Install(file, InstallOptions{Quiet: true})
+	var installErr *InstallError
+	assert.True(t, errors.As(err, &installErr))
+	assert.Len(t, installErr.Errors, 1)
+	assert.IsType(t, &UnsafeArchiveError{}, installErr.Errors[0])
+	assert.EqualError(t, installErr.Errors[0], "refusing to extract hostile/package: root/../../../evil: path is absolute or outside of the package")
+	assert.False(t, exists(filepath.Join(filepath.Dir(file.Dirpath()), "evil")))
+	_, err = os.Stat(filepath.Join(file.Dirpath(), "vendor"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExtractArchivePreservesModes(t *testing.T) {
+	entries := []testEntry{
+		{name: "root/", mode: os.ModeDir | 0755},
+		{name: "root/bin/", mode: os.ModeDir | 0555},
+		{name: "root/bin/tool", mode: 0775, contents: "#!/usr/bin/env php"},
+		{name: "root/src/A.php", mode: 0640, contents: "<?php"},
+		{name: "root/tool", mode: os.ModeSymlink | 0777, linkname: "bin/tool"},
+	}
+	tests := map[string][]byte{
+		"zip": zipEntries(t, entries...),
+		"tar": tarEntries(t, entries...),
+	}
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "compote_extract_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			archive := filepath.Join(dir, "archive")
+			assert.Nil(t, ioutil.WriteFile(archive, contents, 0644))
+			format := formatZip
+			if name == "tar" {
+				format = formatTar
+			}
+
+			dest := filepath.Join(dir, "dest")
+			root, err := extractArchive(archive, format, dest, ExtractLimits{})
+			assert.Nil(t, err)
+			assert.Equal(t, filepath.Join(dest, "root"), root)
+			for path, mode := range map[string]os.FileMode{
+				"root/bin":       os.ModeDir | 0755,
+				"root/bin/tool":  0775,
+				"root/src/A.php": 0640,
+			} {
+				info, err := os.Lstat(filepath.Join(dest, path))
+				if assert.Nil(t, err) {
+					assert.Equal(t, mode, info.Mode(), path)
+				}
+			}
+			link, err := os.Readlink(filepath.Join(dest, "root", "tool"))
+			assert.Nil(t, err)
+			assert.Equal(t, "bin/tool", link)
+		})
+	}
+}
+
+func TestExtractArchiveRoot(t *testing.T) {
+	file := func(name string) testEntry {
+		return testEntry{name: name, mode: 0644, contents: name}
+	}
+	dir := func(name string) testEntry {
+		return testEntry{name: name, mode: os.ModeDir | 0755}
+	}
+	tests := map[string]struct {
+		entries []testEntry
+		root    string
+		files   []string
+	}{
+		"single root directory": {
+			entries: []testEntry{dir("root/"), file("root/composer.json")},
+			root:    "root",
+			files:   []string{"composer.json"},
+		},
+		"first entry is a file": {
+			entries: []testEntry{file("root/composer.json"), dir("root/src/"), file("root/src/A.php")},
+			root:    "root",
+			files:   []string{"composer.json", "src/A.php"},
+		},
+		"macOS metadata beside the root": {
+			entries: []testEntry{file("root/composer.json"), file("__MACOSX/root/._composer.json"), file(".DS_Store")},
+			root:    "root",
+			files:   []string{"composer.json"},
+		},
+		"flat archive": {
+			entries: []testEntry{file("composer.json"), file("src/A.php")},
+			files:   []string{"composer.json", "src/A.php"},
+		},
+		"several top-level directories": {
+			entries: []testEntry{file("src/A.php"), file("tests/ATest.php")},
+			files:   []string{"src/A.php", "tests/ATest.php"},
+		},
+		"single top-level file": {
+			entries: []testEntry{file("functions.php")},
+			files:   []string{"functions.php"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmp, err := ioutil.TempDir("", "compote_extract_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(tmp)
+			archive := filepath.Join(tmp, "archive")
+			assert.Nil(t, ioutil.WriteFile(archive, zipEntries(t, tc.entries...), 0644))
+
+			dest := filepath.Join(tmp, "dest")
+			root, err := extractArchive(archive, formatZip, dest, ExtractLimits{})
+			assert.Nil(t, err)
+			assert.Equal(t, filepath.Join(dest, tc.root), root)
+			for _, f := range tc.files {
+				assert.FileExists(t, filepath.Join(root, filepath.FromSlash(f)))
+			}
+		})
+	}
+}
+
+func TestExtractEmptyArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "compote_extract_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, "archive")
+	assert.Nil(t, ioutil.WriteFile(archive, zipEntries(t), 0644))
+
+	_, err = extractArchive(archive, formatZip, filepath.Join(tmp, "dest"), ExtractLimits{})
+	assert.EqualError(t, err, "archive is empty")
+}
+
+func TestZipMode(t *testing.T) {
+	unix := func(name string, mode os.FileMode) zip.FileHeader {
+		h := zip.FileHeader{Name: name}
+		h.SetMode(mode)
+		return h
+	}
+	tests := map[string]struct {
+		header zip.FileHeader
+		mode   os.FileMode
+	}{
+		"unix executable":   {unix("tool", 0755), 0755},
+		"unix symlink":      {unix("link", os.ModeSymlink|0777), os.ModeSymlink | 0777},
+		"unix without mode": {zip.FileHeader{Name: "file", CreatorVersion: 3 << 8}, 0644},
+		"msdos file":        {zip.FileHeader{Name: "file"}, 0644},
+		"msdos directory":   {zip.FileHeader{Name: "dir/"}, os.ModeDir | 0755},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.mode, zipMode(tc.header))
+		})
+	}
+}
+
+func TestExtractPackageFlatArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "compote_extract_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, "archive")
+	entries := []testEntry{
+		{name: "composer.json", mode: 0644},
+		{name: "src/A.php", mode: 0644},
+		{name: "__MACOSX/._composer.json", mode: 0644},
+		{name: ".DS_Store", mode: 0644},
+	}
+	assert.Nil(t, ioutil.WriteFile(archive, zipEntries(t, entries...), 0644))
+
+	vendor := filepath.Join(tmp, "vendor")
+	assert.Nil(t, os.MkdirAll(vendor, 0755))
+	assert.Nil(t, extractPackage(vendor, archive, formatZip, Package{Name: "foo/bar"}, ExtractLimits{}))
+
+	packagePath := filepath.Join(vendor, "foo", "bar")
+	info, err := os.Stat(packagePath)
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.ModeDir|0755, info.Mode())
+	}
+	assert.FileExists(t, filepath.Join(packagePath, "src", "A.php"))
+	assert.False(t, exists(filepath.Join(packagePath, "__MACOSX")))
+	assert.False(t, exists(filepath.Join(packagePath, ".DS_Store")))
+}
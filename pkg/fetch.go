@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Fetch downloads and verifies every dist archive in file into the cache
+// configured by options without touching the vendor directory. Packages that
+// are already cached are skipped. Only SkipDev, Quiet, Jobs, Cache,
+// Download and RequireChecksums are used from options.
+func Fetch(file DependencyFile, options InstallOptions) error {
+	if options.Cache == nil {
+		return errors.New("fetch requires a cache")
+	}
+
+	var (
+		packages []Package
+		errs     []error
+	)
+	for _, p := range file.Dependencies(!options.SkipDev) {
+		// Path dists are read from disk on install and source-only packages
+		// are cloned, so neither is cached.
+		if isPathDist(p) || !hasDist(p) {
+			continue
+		}
+		if options.RequireChecksums && p.Distribution.Shasum == "" {
+			errs = append(errs, &MissingChecksumError{Package: p.Name})
+			continue
+		}
+		if _, ok := options.Cache.path(p); !ok {
+			errs = append(errs, fmt.Errorf("unable to cache %s without a dist reference or shasum", p.Name))
+			continue
+		}
+		if !options.Cache.Has(p) {
+			packages = append(packages, p)
+		}
+	}
+	if len(errs) > 0 {
+		return &InstallError{Errors: errs}
+	}
+	if !options.Quiet {
+		fmt.Printf("Fetching %d packages\n", len(packages))
+	}
+	start := time.Now()
+
+	dir, err := ioutil.TempDir("", "compote_fetch_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	dl := newDownloader(options.Download)
+	errs = forEachPackage(packages, options.jobs(), func(p Package) error {
+		archive := filepath.Join(dir, cacheKey(p))
+		defer os.Remove(archive)
+		err := dl.download(archive, p)
+		if err != nil {
+			return err
+		}
+		err = options.Cache.Store(p, archive)
+		if err == nil && !options.Quiet {
+			fmt.Print(".")
+		}
+		return err
+	})
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return &InstallError{Errors: errs}
+	}
+	if !options.Quiet {
+		fmt.Printf("\nFetched %d packages in %s\n", len(packages), time.Since(start))
+	}
+	return nil
+}
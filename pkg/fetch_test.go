@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Write(zipArchive(t, "package-abc123/", map[string]string{"composer.json": "{}"}))
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		packages []Package
+		cached   []Package
+		requests map[string]int
+		passes   bool
+	}{
+		"every package is fetched": {
+			packages: []Package{
+				{Name: "composer/semver", Distribution: Distribution{URL: server.URL + "/composer/semver.zip", Reference: "abc123"}},
+				{Name: "doctrine/cache", Distribution: Distribution{URL: server.URL + "/doctrine/cache.zip", Reference: "def456"}},
+			},
+			requests: map[string]int{"/composer/semver.zip": 1, "/doctrine/cache.zip": 1},
+			passes:   true,
+		},
+		"cached packages are skipped": {
+			packages: []Package{
+				{Name: "composer/semver", Distribution: Distribution{URL: server.URL + "/composer/semver.zip", Reference: "abc123"}},
+				{Name: "doctrine/cache", Distribution: Distribution{URL: server.URL + "/doctrine/cache.zip", Reference: "def456"}},
+			},
+			cached: []Package{
+				{Name: "composer/semver", Distribution: Distribution{URL: server.URL + "/composer/semver.zip", Reference: "abc123"}},
+			},
+			requests: map[string]int{"/doctrine/cache.zip": 1},
+			passes:   true,
+		},
+		"unaddressable packages fail": {
+			packages: []Package{
+				{Name: "composer/semver", Distribution: Distribution{URL: server.URL + "/composer/semver.zip"}},
+			},
+			requests: map[string]int{},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for path := range requests {
+				delete(requests, path)
+			}
+			dir, err := ioutil.TempDir("", "compote_cache_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			cache := NewCache(dir)
+			archive := filepath.Join(dir, "archive.zip")
+			assert.Nil(t, ioutil.WriteFile(archive, []byte("cached"), 0644))
+			for _, p := range tc.cached {
+				assert.Nil(t, cache.Store(p, archive))
+			}
+
+			file, cleanup := newTestLockfile(t, tc.packages...)
+			defer cleanup()
+			err = Fetch(file, InstallOptions{Quiet: true, Cache: cache, Jobs: 1})
+			if !tc.passes {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.requests, requests)
+			for _, p := range tc.packages {
+				assert.True(t, cache.Has(p))
+			}
+			_, err = os.Stat(filepath.Join(file.Dirpath(), "vendor"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -17,6 +17,10 @@ type DependencyFile interface {
 	Fullpath() string
 	Dirpath() string
 	Dependencies(withDev bool) []Package
+	// RootPackage returns the project's own composer.json, or nil when the
+	// project does not have one. It is only read when first asked for, so
+	// commands that do not autoload never fail on it.
+	RootPackage() (*RootPackage, error)
 }
 
 var _ DependencyFile = (*lockfile)(nil)
@@ -25,6 +29,7 @@ type lockfile struct {
 	Readme      []string  `json:"_readme,omitempty"`
 	Packages    []Package `json:"packages"`
 	PackagesDev []Package `json:"packages-dev"`
+	root        *RootPackage
 	filename    string
 	fullpath    string
 }
@@ -49,6 +54,18 @@ func (f *lockfile) Dependencies(withDev bool) []Package {
 	return f.Packages
 }
 
+func (f *lockfile) RootPackage() (*RootPackage, error) {
+	if f.root != nil {
+		return f.root, nil
+	}
+	root, err := loadRootPackage(filepath.Dir(f.fullpath))
+	if err != nil {
+		return nil, err
+	}
+	f.root = root
+	return root, nil
+}
+
 type newLockfileOptions struct {
 	skipLoading bool
 }
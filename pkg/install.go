@@ -1,141 +1,453 @@
 package pkg
 
 import (
-	"archive/zip"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/mholt/archiver"
 	uuid "github.com/satori/go.uuid"
 )
 
-// @todo convert the third param to options
-func Install(file DependencyFile, skipDev bool, quiet bool) error {
-	var packages = make(map[string]Package)
-	pkgs := file.Dependencies(!skipDev)
-	for _, p := range pkgs {
-		packages[p.Name] = p
+// DefaultJobs is the number of concurrent downloads used when
+// InstallOptions.Jobs is unset.
+const DefaultJobs = 8
+
+// InstallOptions controls how Install resolves and writes packages.
+type InstallOptions struct {
+	// SkipDev skips the packages-dev section of the lockfile.
+	SkipDev bool
+	// Quiet suppresses all progress output.
+	Quiet bool
+	// Jobs limits how many packages are downloaded at once. Defaults to
+	// DefaultJobs when unset.
+	Jobs int
+	// ExtractJobs limits how many archives are extracted at once. Defaults
+	// to the number of CPUs when unset.
+	ExtractJobs int
+	// Cache, when set, is used to reuse previously downloaded archives and
+	// to store newly verified ones.
+	Cache *Cache
+	// Download controls retries and timeouts for dist downloads.
+	Download DownloadOptions
+	// Limits bounds the entries and decompressed size of each dist archive.
+	Limits ExtractLimits
+	// Autoload controls how the autoloader is generated once packages are
+	// installed.
+	Autoload AutoloadOptions
+	// Offline installs only from the cache and local file:// archives and
+	// never opens a network connection.
+	Offline bool
+	// PathMode controls how packages with a "path" dist are installed.
+	// Defaults to PathModeSymlink.
+	PathMode PathMode
+	// PreferSource installs packages from their source repository rather
+	// than their dist archive whenever a source is locked. Packages locked
+	// without a dist are always installed from source.
+	PreferSource bool
+	// RequireChecksums fails the install when a package is locked without a
+	// dist shasum, since its download cannot be verified. Path dists and
+	// packages installed from source are never downloaded and so are exempt.
+	RequireChecksums bool
+}
+
+func (o InstallOptions) jobs() int {
+	if o.Jobs <= 0 {
+		return DefaultJobs
 	}
-	wg := new(sync.WaitGroup)
-	wg.Add(len(packages))
-	if !quiet {
-		fmt.Printf("Installing %d direct dependencies\n", len(packages))
+	return o.Jobs
+}
+
+// ChecksumMismatchError is returned when a downloaded dist archive does not
+// match the shasum locked for its package.
+type ChecksumMismatchError struct {
+	Package  string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.Package, e.Expected, e.Actual)
+}
+
+// MissingChecksumError is returned when checksums are required and a package
+// is locked without a dist shasum.
+type MissingChecksumError struct {
+	Package string
+}
+
+func (e *MissingChecksumError) Error() string {
+	return fmt.Sprintf("no dist shasum locked for %s", e.Package)
+}
+
+// OfflineError lists the packages that cannot be installed without network
+// access.
+type OfflineError struct {
+	Packages []string
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("%d packages are not available offline:\n  %s", len(e.Packages), strings.Join(e.Packages, "\n  "))
+}
+
+// InstallError reports every package that failed during an Install.
+type InstallError struct {
+	Errors []error
+}
+
+func (e *InstallError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
 	}
-	start := time.Now()
+	return fmt.Sprintf("unable to install %d packages:\n  %s", len(e.Errors), strings.Join(msgs, "\n  "))
+}
 
-	dir, err := ioutil.TempDir(file.Dirpath(), ".compote_")
+// Install brings the vendor directory next to file in line with the packages
+// it locks by building and executing a Plan. The vendor directory of an
+// earlier install that was interrupted is restored first.
+func Install(file DependencyFile, options InstallOptions) error {
+	if _, err := recoverInstall(file.Dirpath()); err != nil {
+		return fmt.Errorf("unable to restore vendor after an interrupted install: %w", err)
+	}
+	plan, err := NewPlan(file, !options.SkipDev)
 	if err != nil {
 		return err
 	}
-	for _, p := range packages {
-		go installPackage(wg, dir, p, quiet)
+	return plan.Execute(options)
+}
+
+// Execute applies the plan. When vendor already records what it contains,
+// only packages that were added or changed are installed and removed
+// packages are deleted; unchanged packages are left in place. SkipDev is
+// ignored since the plan has already chosen the packages to install.
+func (plan *Plan) Execute(options InstallOptions) error {
+	if plan.Empty() {
+		if !options.Quiet {
+			fmt.Print(plan)
+		}
+		return writeAutoload(plan.vendorDir, plan.projectDir, plan.packages, plan.root, options.Autoload, options.Quiet)
 	}
-	wg.Wait()
+	installs, removals := plan.installs(), plan.removals()
 
-	vendorDir := filepath.Join(file.Dirpath(), "vendor")
-	os.RemoveAll(vendorDir)
-	err = os.Rename(dir, vendorDir)
-	if err != nil {
-		return err
+	var (
+		packages = make(map[string]Package)
+		paths    []Package
+		sources  []Package
+		errs     []error
+	)
+	for _, p := range installs {
+		if isPathDist(p) {
+			paths = append(paths, p)
+			continue
+		}
+		if installFromSource(p, options.PreferSource) {
+			sources = append(sources, p)
+			continue
+		}
+		if options.RequireChecksums && p.Distribution.Shasum == "" {
+			errs = append(errs, &MissingChecksumError{Package: p.Name})
+		}
+		packages[p.Name] = p
+	}
+	if len(errs) > 0 {
+		return &InstallError{Errors: errs}
 	}
-	if !quiet {
-		fmt.Printf("\nInstalled %d packages in %s\n", len(packages), time.Since(start))
+	if options.Offline {
+		var missing []string
+		for _, p := range packages {
+			if !availableOffline(p, options.Cache) {
+				missing = append(missing, p.Name)
+			}
+		}
+		for _, p := range sources {
+			missing = append(missing, p.Name)
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return &OfflineError{Packages: missing}
+		}
 	}
+	if !options.Quiet {
+		fmt.Printf("Installing %d direct dependencies\n", len(installs))
+		if len(removals) > 0 {
+			fmt.Printf("Removing %d packages\n", len(removals))
+		}
+	}
+	start := time.Now()
 
-	// Add the installed.json file for autoloading
-	err = os.Mkdir(filepath.Join(vendorDir, "composer"), 0755)
-	if !os.IsExist(err) && err != nil {
+	dir, err := ioutil.TempDir(plan.projectDir, ".compote_")
+	if err != nil {
 		return err
 	}
-
-	var installedFile *os.File
-	installedFile, err = os.Open(filepath.Join(vendorDir, "composer", "installed.json"))
-	if !os.IsNotExist(err) && err != nil {
+	errs = installPackages(dir, packages, options)
+	for _, p := range paths {
+		if err := installPathPackage(dir, plan.vendorDir, plan.projectDir, p, options.PathMode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, forEachPackage(sources, options.jobs(), func(p Package) error {
+		err := installSourcePackage(dir, p)
+		if err == nil && !options.Quiet {
+			fmt.Print(".")
+		}
 		return err
-	} else if os.IsNotExist(err) {
-		installedFile, err = os.Create(filepath.Join(vendorDir, "composer", "installed.json"))
+	})...)
+	// Leave any existing vendor directory untouched unless every package
+	// installed cleanly.
+	if len(errs) > 0 {
+		os.RemoveAll(dir)
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return &InstallError{Errors: errs}
 	}
-	defer installedFile.Close()
+
+	// Keep whatever vendor holds now until the new one is fully in place so
+	// that a failure at any point can put it back, and so Rollback can
+	// restore it later.
+	backup, err := newSnapshot(plan.projectDir, !plan.incremental)
 	if err != nil {
+		os.RemoveAll(dir)
 		return err
 	}
-	installedFileJSON, err := json.MarshalIndent(pkgs, "", "    ")
+	if plan.incremental {
+		err = backup.replace(dir, plan.vendorDir, installs, removals)
+		os.RemoveAll(dir)
+	} else {
+		err = backup.swap(dir, plan.vendorDir)
+	}
 	if err != nil {
+		os.RemoveAll(dir)
 		return err
 	}
-	fmt.Fprintf(installedFile, string(installedFileJSON)+"\n")
 
+	// Record what vendor now contains for autoloading and later installs.
+	if err := writeInstalled(plan.vendorDir, plan.packages); err != nil {
+		backup.restore(plan.vendorDir)
+		return err
+	}
+	if err := writeAutoload(plan.vendorDir, plan.projectDir, plan.packages, plan.root, options.Autoload, options.Quiet); err != nil {
+		backup.restore(plan.vendorDir)
+		return fmt.Errorf("unable to generate the autoloader: %w", err)
+	}
+	if err := backup.commit(plan.projectDir); err != nil {
+		return err
+	}
+	if !options.Quiet {
+		fmt.Printf("\nInstalled %d packages in %s\n", len(installs), time.Since(start))
+	}
 	return nil
 }
 
-// @todo need a way to handle errors here
-func installPackage(wg *sync.WaitGroup, dir string, p Package, quiet bool) error {
-	defer wg.Done()
+// installPackages downloads and extracts packages into dir using separate
+// worker pools for each step, returning every error encountered.
+func installPackages(dir string, packages map[string]Package, options InstallOptions) []error {
+	type downloaded struct {
+		pkg     Package
+		archive string
+		format  archiveFormat
+	}
+	var (
+		mu          sync.Mutex
+		errs        []error
+		queue       = make(chan Package)
+		extracts    = make(chan downloaded)
+		downloaders = new(sync.WaitGroup)
+		extractors  = new(sync.WaitGroup)
+	)
+	dl := newDownloader(options.Download)
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
 
-	archive := filepath.Join(dir, uuid.NewV4().String()+".zip")
-	out, err := os.Create(archive)
-	if err != nil {
-		return err
+	jobs := options.jobs()
+	downloaders.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer downloaders.Done()
+			for p := range queue {
+				archive := filepath.Join(dir, uuid.NewV4().String())
+				err := fetchArchive(archive, p, options, dl)
+				var format archiveFormat
+				if err == nil {
+					format, err = identifyArchive(archive, p)
+				}
+				if err != nil {
+					os.Remove(archive)
+					fail(err)
+					continue
+				}
+				extracts <- downloaded{pkg: p, archive: archive, format: format}
+			}
+		}()
 	}
-	defer out.Close()
-	resp, err := http.Get(p.Distribution.URL)
-	if err != nil {
-		return err
+
+	extractJobs := options.ExtractJobs
+	if extractJobs <= 0 {
+		extractJobs = runtime.NumCPU()
 	}
-	defer resp.Body.Close()
+	extractors.Add(extractJobs)
+	for i := 0; i < extractJobs; i++ {
+		go func() {
+			defer extractors.Done()
+			for d := range extracts {
+				err := extractPackage(dir, d.archive, d.format, d.pkg, options.Limits)
+				os.Remove(d.archive)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				if !options.Quiet {
+					fmt.Print(".")
+				}
+			}
+		}()
+	}
+
+	for _, p := range packages {
+		queue <- p
+	}
+	close(queue)
+	downloaders.Wait()
+	close(extracts)
+	extractors.Wait()
 
-	_, err = io.Copy(out, resp.Body)
+	return errs
+}
 
+// forEachPackage calls fn for every package using at most jobs goroutines and
+// returns every error encountered.
+func forEachPackage(packages []Package, jobs int, fn func(Package) error) []error {
 	var (
-		first    string
-		firstSet bool
+		mu      sync.Mutex
+		errs    []error
+		queue   = make(chan Package)
+		workers = new(sync.WaitGroup)
 	)
-	// @todo Would be nice to only have to walk the first file to get the dir name.
-	err = archiver.Walk(archive, func(f archiver.File) error {
-		zfh, ok := f.Header.(zip.FileHeader)
-		if !ok {
-			return fmt.Errorf("error walking %s", zfh.Name)
-		}
-		if !firstSet {
-			first = zfh.Name
-			firstSet = true
-		}
-		return nil
-	})
-	err = archiver.Unarchive(archive, dir)
+	workers.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer workers.Done()
+			for p := range queue {
+				if err := fn(p); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, p := range packages {
+		queue <- p
+	}
+	close(queue)
+	workers.Wait()
+	return errs
+}
+
+// extractPackage unpacks a downloaded dist archive into its install path
+// within dir.
+func extractPackage(dir string, archive string, format archiveFormat, p Package, limits ExtractLimits) error {
+	// Extract each package into its own staging directory so that packages
+	// extracted at the same time can never collide, whatever their archives
+	// hold.
+	staging, err := ioutil.TempDir(dir, ".extract_")
 	if err != nil {
 		return err
 	}
-	packagePath := filepath.Join(dir, p.Name)
-	packageName := strings.Split(p.Name, "/")
-	err = os.MkdirAll(filepath.Join(dir, packageName[0]), os.ModePerm)
+	defer os.RemoveAll(staging)
+	// TempDir creates the staging directory readable only by its owner, and
+	// a flat archive is moved into place along with the directory it was
+	// extracted into, so extract into a directory with the usual mode.
+	root, err := extractArchive(archive, format, filepath.Join(staging, "package"), limits)
+	var unsafe *UnsafeArchiveError
+	if errors.As(err, &unsafe) {
+		unsafe.Package = p.Name
+		return unsafe
+	} else if err != nil {
+		return fmt.Errorf("unable to extract %s: %w", p.Name, err)
+	}
+
+	packagePath, err := installPath(dir, p)
 	if err != nil {
 		return err
 	}
-
-	err = os.Rename(filepath.Join(dir, first), packagePath)
+	err = os.MkdirAll(filepath.Dir(packagePath), os.ModePerm)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
-	err = os.Remove(archive)
+	err = os.Rename(root, packagePath)
+	if err != nil {
+		return fmt.Errorf("unable to move %s into place: %w", p.Name, err)
+	}
+
+	return nil
+}
+
+// installPath returns where p is placed within dir, which is
+// dir/<vendor>/<name> followed by the package's target-dir when it has one.
+func installPath(dir string, p Package) (string, error) {
+	packagePath := filepath.Join(dir, filepath.FromSlash(p.Name))
+	if p.TargetDir == "" {
+		return packagePath, nil
+	}
+	targetDir, ok := entryPath(p.TargetDir)
+	if !ok {
+		return "", fmt.Errorf("invalid target-dir %q for %s", p.TargetDir, p.Name)
+	}
+	return filepath.Join(packagePath, filepath.FromSlash(targetDir)), nil
+}
+
+func fetchArchive(path string, p Package, options InstallOptions, dl *downloader) error {
+	cache := options.Cache
+	if cache != nil {
+		hit, err := cache.Fetch(p, path)
+		if err != nil || hit {
+			return err
+		}
+	}
+	if _, local := localArchive(p); options.Offline && !local {
+		return &OfflineError{Packages: []string{p.Name}}
+	}
+	err := dl.download(path, p)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
+	if cache != nil {
+		// A cache that cannot be written to should not fail the install.
+		cache.Store(p, path)
+	}
+	return nil
+}
 
-	if !quiet {
-		fmt.Print(".")
+// availableOffline reports whether a package can be installed without
+// network access.
+func availableOffline(p Package, cache *Cache) bool {
+	if _, local := localArchive(p); local {
+		return true
 	}
+	return cache != nil && cache.Has(p)
+}
 
+// verifyChecksum compares the sha1 of a downloaded archive against the shasum
+// locked for the package. Packages locked without a shasum are not verified.
+func verifyChecksum(p Package, actual string) error {
+	if p.Distribution.Shasum == "" {
+		return nil
+	}
+	if !strings.EqualFold(p.Distribution.Shasum, actual) {
+		return &ChecksumMismatchError{
+			Package:  p.Name,
+			Expected: p.Distribution.Shasum,
+			Actual:   actual,
+		}
+	}
 	return nil
 }
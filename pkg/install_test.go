@@ -1,11 +1,21 @@
 package pkg
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,7 +43,7 @@ func TestInstall(t *testing.T) {
 			)
 			file, err = newLockfile(tc.fullpath)
 			assert.Nil(t, err)
-			err = Install(file, false, true)
+			err = Install(file, InstallOptions{Quiet: true})
 			assert.Nil(t, err, fmt.Sprintf("Check %s for possible undeleted .compote_ directories.", file.Fullpath()))
 			err = filepath.Walk(file.Dirpath(), func(path string, info os.FileInfo, err error) error {
 				if info.IsDir() {
@@ -51,3 +61,209 @@ func TestInstall(t *testing.T) {
 		})
 	}
 }
+
+func TestInstallChecksums(t *testing.T) {
+	archive := zipArchive(t, "composer-semver-46d9139/", map[string]string{
+		"composer.json": `{"name": "composer/semver"}`,
+	})
+	sum := sha1.Sum(archive)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		shasum           string
+		requireChecksums bool
+		err              interface{}
+	}{
+		"matching shasum is installed": {
+			shasum: hex.EncodeToString(sum[:]),
+		},
+		"uppercase shasum is installed": {
+			shasum: strings.ToUpper(hex.EncodeToString(sum[:])),
+		},
+		"mismatched shasum fails": {
+			shasum: "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+			err:    &ChecksumMismatchError{},
+		},
+		"empty shasum is installed": {},
+		"empty shasum fails when checksums are required": {
+			requireChecksums: true,
+			err:              &MissingChecksumError{},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, cleanup := newTestLockfile(t, Package{
+				Name:    "composer/semver",
+				Version: "1.5.0",
+				Distribution: Distribution{
+					Type:   "zip",
+					URL:    server.URL + "/composer/semver.zip",
+					Shasum: tc.shasum,
+				},
+			})
+			defer cleanup()
+
+			err := Install(file, InstallOptions{Quiet: true, RequireChecksums: tc.requireChecksums})
+			vendorDir := filepath.Join(file.Dirpath(), "vendor")
+			if tc.err == nil {
+				assert.Nil(t, err)
+				assert.FileExists(t, filepath.Join(vendorDir, "composer", "semver", "composer.json"))
+				return
+			}
+			var installErr *InstallError
+			assert.True(t, errors.As(err, &installErr))
+			assert.Len(t, installErr.Errors, 1)
+			assert.IsType(t, tc.err, installErr.Errors[0])
+			assert.Contains(t, err.Error(), "composer/semver")
+			_, err = os.Stat(vendorDir)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestInstallFailuresLeaveVendorUntouched(t *testing.T) {
+	archive := zipArchive(t, "composer-semver-46d9139/", map[string]string{
+		"composer.json": `{"name": "composer/semver"}`,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/composer/semver.zip":
+			w.Write(archive)
+		case "/broken/zip.zip":
+			w.Write([]byte("not a zip archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	file, cleanup := newTestLockfile(t,
+		Package{Name: "composer/semver", Distribution: Distribution{Type: "zip", URL: server.URL + "/composer/semver.zip"}},
+		Package{Name: "missing/package", Distribution: Distribution{Type: "zip", URL: server.URL + "/missing/package.zip"}},
+		Package{Name: "broken/zip", Distribution: Distribution{Type: "zip", URL: server.URL + "/broken/zip.zip"}},
+	)
+	defer cleanup()
+	existing := filepath.Join(file.Dirpath(), "vendor", "autoload.php")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(existing), 0755))
+	assert.Nil(t, ioutil.WriteFile(existing, []byte("<?php\n"), 0644))
+
+	err := Install(file, InstallOptions{Quiet: true})
+	var installErr *InstallError
+	assert.True(t, errors.As(err, &installErr))
+	assert.Len(t, installErr.Errors, 2)
+	assert.Contains(t, err.Error(), "missing/package")
+	assert.Contains(t, err.Error(), "404")
+	assert.Contains(t, err.Error(), "broken/zip")
+
+	assert.FileExists(t, existing)
+	temps, err := filepath.Glob(filepath.Join(file.Dirpath(), ".compote_*"))
+	assert.Nil(t, err)
+	assert.Empty(t, temps)
+}
+
+func TestInstallLimitsConcurrentDownloads(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		w.Write(zipArchive(t, "package-abc123/", map[string]string{"composer.json": "{}"}))
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	var packages []Package
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("vendor/package-%d", i)
+		packages = append(packages, Package{Name: name, Distribution: Distribution{Type: "zip", URL: server.URL + "/" + name + ".zip"}})
+	}
+	file, cleanup := newTestLockfile(t, packages...)
+	defer cleanup()
+
+	err := Install(file, InstallOptions{Quiet: true, Jobs: 2, ExtractJobs: 1})
+	assert.Nil(t, err)
+	assert.True(t, peak <= 2, fmt.Sprintf("expected at most 2 concurrent downloads, saw %d", peak))
+	for _, p := range packages {
+		assert.DirExists(t, filepath.Join(file.Dirpath(), "vendor", p.Name))
+	}
+}
+
+func TestInstallTargetDir(t *testing.T) {
+	archive := zipArchive(t, "symfony-yaml-2.0/", map[string]string{
+		"Parser.php": `<?php namespace Symfony\Component\Yaml; class Parser {}`,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	yaml := Package{
+		Name:         "symfony/yaml",
+		Version:      "v2.0.0",
+		Distribution: Distribution{Type: "zip", URL: server.URL + "/symfony/yaml.zip"},
+		TargetDir:    "Symfony/Component/Yaml",
+		Autoload:     Autoload{PSR0: FlexPSR{Single: &map[string]string{`Symfony\Component\Yaml`: ""}}},
+	}
+	file, cleanup := newTestLockfile(t, yaml)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	assert.FileExists(t, filepath.Join(vendorDir, "symfony", "yaml", "Symfony", "Component", "Yaml", "Parser.php"))
+	contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", "autoload_namespaces.php"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), `'Symfony\\Component\\Yaml' => array($vendorDir . '/symfony/yaml'),`)
+
+	// A second install finds the package where the first put it.
+	plan, err := NewPlan(file, true)
+	assert.Nil(t, err)
+	assert.True(t, plan.Empty())
+
+	for _, targetDir := range []string{"../escape", "/absolute"} {
+		yaml.TargetDir = targetDir
+		_, err := installPath(vendorDir, yaml)
+		assert.NotNil(t, err, targetDir)
+	}
+}
+
+// zipArchive builds an in-memory zip archive with every file nested under root.
+func zipArchive(t *testing.T, root string, files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	if root != "" {
+		_, err := w.Create(root)
+		assert.Nil(t, err)
+	}
+	for name, contents := range files {
+		f, err := w.Create(root + name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+// newTestLockfile creates a lockfile for packages within a new temporary
+// project directory. The returned func removes the directory.
+func newTestLockfile(t *testing.T, packages ...Package) (*lockfile, func()) {
+	dir, err := ioutil.TempDir("", "compote_test_")
+	assert.Nil(t, err)
+	lf, err := newLockfile(filepath.Join(dir, "composer.lock"), newLockfileOptions{skipLoading: true})
+	assert.Nil(t, err)
+	lf.Packages = packages
+	return lf, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// installedPath returns the location of installed.json within vendorDir.
+func installedPath(vendorDir string) string {
+	return filepath.Join(vendorDir, "composer", "installed.json")
+}
+
+// readInstalled loads the packages recorded in vendor/composer/installed.json,
+// reporting false when vendor has no record of what it contains. Both the
+// composer 1 list format and the composer 2 object format are understood.
+func readInstalled(vendorDir string) ([]Package, bool, error) {
+	contents, err := ioutil.ReadFile(installedPath(vendorDir))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var packages []Package
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("{")) {
+		var installed struct {
+			Packages []Package `json:"packages"`
+		}
+		err = json.Unmarshal(contents, &installed)
+		packages = installed.Packages
+	} else {
+		err = json.Unmarshal(contents, &packages)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return packages, true, nil
+}
+
+// writeInstalled records packages in vendor/composer/installed.json.
+func writeInstalled(vendorDir string, packages []Package) error {
+	err := os.MkdirAll(filepath.Dir(installedPath(vendorDir)), 0755)
+	if err != nil {
+		return err
+	}
+	installedJSON, err := json.MarshalIndent(packages, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(installedPath(vendorDir), append(installedJSON, '\n'), 0644)
+}
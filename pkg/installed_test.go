@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalInstall(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		w.Write(zipArchive(t, "package-root/", map[string]string{"composer.json": r.URL.Path}))
+	}))
+	defer server.Close()
+	release := func(name, version, reference string) Package {
+		return Package{
+			Name:         name,
+			Version:      version,
+			Distribution: Distribution{Type: "zip", URL: server.URL + "/" + name + "/" + reference, Reference: reference},
+		}
+	}
+
+	file, cleanup := newTestLockfile(t,
+		release("acme/unchanged", "1.0.0", "aaa"),
+		release("acme/upgraded", "1.0.0", "bbb"),
+		release("acme/rereferenced", "dev-main", "ccc"),
+		release("gone/removed", "1.0.0", "ddd"),
+	)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	marker := filepath.Join(vendorDir, "acme", "unchanged", "marker")
+	assert.Nil(t, ioutil.WriteFile(marker, []byte("untouched"), 0644))
+
+	requests = nil
+	file.Packages = []Package{
+		release("acme/unchanged", "1.0.0", "aaa"),
+		release("acme/upgraded", "1.1.0", "eee"),
+		release("acme/rereferenced", "dev-main", "fff"),
+		release("acme/added", "1.0.0", "ggg"),
+	}
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+
+	assert.ElementsMatch(t, []string{"/acme/upgraded/eee", "/acme/rereferenced/fff", "/acme/added/ggg"}, requests)
+	assert.FileExists(t, marker)
+	for name, reference := range map[string]string{"acme/upgraded": "eee", "acme/rereferenced": "fff", "acme/added": "ggg"} {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, name, "composer.json"))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasSuffix(string(contents), reference))
+	}
+	_, err := os.Stat(filepath.Join(vendorDir, "gone"))
+	assert.True(t, os.IsNotExist(err))
+	temps, err := filepath.Glob(filepath.Join(file.Dirpath(), ".compote_*"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(file.Dirpath(), snapshotDirName)}, temps)
+
+	installed, ok, err := readInstalled(vendorDir)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, file.Packages, installed)
+
+	requests = nil
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	assert.Empty(t, requests)
+
+	assert.Nil(t, os.RemoveAll(filepath.Join(vendorDir, "acme", "added")))
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	assert.Equal(t, []string{"/acme/added/ggg"}, requests)
+}
+
+func TestReadInstalled(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		packages []string
+		ok       bool
+		passes   bool
+	}{
+		"missing installed.json": {
+			passes: true,
+		},
+		"composer 1 format": {
+			contents: `[{"name": "composer/semver", "version": "1.5.0"}]`,
+			packages: []string{"composer/semver"},
+			ok:       true,
+			passes:   true,
+		},
+		"composer 2 format": {
+			contents: `{"packages": [{"name": "composer/semver", "version": "1.5.0"}], "dev": true}`,
+			packages: []string{"composer/semver"},
+			ok:       true,
+			passes:   true,
+		},
+		"corrupt installed.json": {
+			contents: `[{"name": `,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			vendorDir, err := ioutil.TempDir("", "compote_vendor_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(vendorDir)
+			if tc.contents != "" {
+				assert.Nil(t, os.MkdirAll(filepath.Join(vendorDir, "composer"), 0755))
+				assert.Nil(t, ioutil.WriteFile(installedPath(vendorDir), []byte(tc.contents), 0644))
+			}
+
+			packages, ok, err := readInstalled(vendorDir)
+			if !tc.passes {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.ok, ok)
+			var names []string
+			for _, p := range packages {
+				names = append(names, p.Name)
+			}
+			assert.Equal(t, tc.packages, names)
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package pkg
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,18 +22,38 @@ type LockfileContents struct {
 }
 
 type Package struct {
-	Name         string       `json:"name"`
-	Version      string       `json:"version"`
-	Distribution Distribution `json:"dist"`
-	Description  string       `json:"description"`
-	Autoload     Autoload     `json:"autoload"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Source       Source            `json:"source"`
+	Distribution Distribution      `json:"dist"`
+	Require      map[string]string `json:"require,omitempty"`
+	Description  string            `json:"description"`
+	Autoload     Autoload          `json:"autoload"`
+	// TargetDir is the legacy location, relative to the package directory,
+	// that a PSR-0 package is installed into.
+	TargetDir string `json:"target-dir,omitempty"`
+	// IncludePath lists directories, relative to the package directory, to
+	// add to PHP's include_path.
+	IncludePath []string `json:"include-path,omitempty"`
 }
 
-type Distribution struct {
+type Source struct {
 	Type      string `json:"type"`
 	URL       string `json:"url"`
 	Reference string `json:"reference"`
-	Shasum    string `json:"shasum"`
+}
+
+type Distribution struct {
+	Type      string   `json:"type"`
+	URL       string   `json:"url"`
+	Reference string   `json:"reference"`
+	Shasum    string   `json:"shasum"`
+	Mirrors   []Mirror `json:"mirrors,omitempty"`
+}
+
+type Mirror struct {
+	URL       string `json:"url"`
+	Preferred bool   `json:"preferred"`
 }
 
 // @doc https://engineering.bitnami.com/articles/dealing-with-json-with-non-homogeneous-types-in-go.html
@@ -117,28 +136,58 @@ type FlexPSR struct {
 	Multiple *map[string][]string `json:",omitempty"`
 }
 
+// UnmarshalJSON reads a psr-0 or psr-4 section, whose prefixes each map to
+// either a single path or a list of paths. A section made up only of single
+// paths is kept as Single, anything else as Multiple.
 func (fpsr *FlexPSR) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
 	var (
 		single   = map[string]string{}
 		multiple = map[string][]string{}
 	)
-
-	if bytes.Contains(b, []byte("[")) {
-		if err := json.Unmarshal(b, &multiple); err != nil {
-			return err
+	for prefix, value := range raw {
+		var path string
+		if err := json.Unmarshal(value, &path); err == nil {
+			single[prefix] = path
+			multiple[prefix] = []string{path}
+			continue
 		}
-		fpsr.Multiple = &multiple
-	} else {
-		if err := json.Unmarshal(b, &single); err != nil {
-			return err
+		var paths []string
+		if err := json.Unmarshal(value, &paths); err != nil {
+			return fmt.Errorf("invalid paths for prefix %q: %w", prefix, err)
 		}
-		fpsr.Single = &single
-
+		multiple[prefix] = paths
 	}
 
+	if len(single) == len(multiple) {
+		fpsr.Single = &single
+	} else {
+		fpsr.Multiple = &multiple
+	}
 	return nil
 }
 
+// Mappings returns every prefix of a psr-0 or psr-4 section with its paths,
+// however the section was written.
+func (fpsr FlexPSR) Mappings() map[string][]string {
+	mappings := map[string][]string{}
+	if fpsr.Single != nil {
+		for prefix, path := range *fpsr.Single {
+			mappings[prefix] = []string{path}
+		}
+	}
+	if fpsr.Multiple != nil {
+		for prefix, paths := range *fpsr.Multiple {
+			mappings[prefix] = append(mappings[prefix], paths...)
+		}
+	}
+	return mappings
+}
+
 // parsePath will attempt to find the path of the composer.lock file moving in priority of:
 // 1. if the path is an existing composer.lock file, use that file
 // 2: if the path is a directory, look for a composer.lock file in the passed directory
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,3 +51,45 @@ func TestLockfileLoading(t *testing.T) {
 		})
 	}
 }
+
+func TestFlexPSRUnmarshal(t *testing.T) {
+	tests := map[string]struct {
+		json     string
+		single   bool
+		mappings map[string][]string
+		passes   bool
+	}{
+		"single paths": {
+			json:     `{"App\\": "app/"}`,
+			single:   true,
+			mappings: map[string][]string{`App\`: {"app/"}},
+			passes:   true,
+		},
+		"lists of paths": {
+			json:     `{"App\\": ["app/", "src/"]}`,
+			mappings: map[string][]string{`App\`: {"app/", "src/"}},
+			passes:   true,
+		},
+		"single paths mixed with lists": {
+			json:     `{"App\\": "app/", "Tests\\": ["tests/", "t2/"]}`,
+			mappings: map[string][]string{`App\`: {"app/"}, `Tests\`: {"tests/", "t2/"}},
+			passes:   true,
+		},
+		"invalid paths": {
+			json: `{"App\\": 1}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var psr FlexPSR
+			err := json.Unmarshal([]byte(tc.json), &psr)
+			if !tc.passes {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.single, psr.Single != nil)
+			assert.Equal(t, tc.mappings, psr.Mappings())
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RewriteRule redirects dist downloads, for example to an internal artifact
+// proxy. A rule matches either every URL on Host, replacing the host with To,
+// or every URL starting with Prefix, replacing the prefix with To.
+type RewriteRule struct {
+	Host   string
+	Prefix string
+	To     string
+}
+
+// rewrite applies the rule to src, reporting whether it matched.
+func (r RewriteRule) rewrite(src string) (string, bool) {
+	if r.Prefix != "" {
+		if !strings.HasPrefix(src, r.Prefix) {
+			return src, false
+		}
+		return r.To + strings.TrimPrefix(src, r.Prefix), true
+	}
+	if r.Host != "" {
+		u, err := url.Parse(src)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return src, false
+		}
+		u.Host = r.To
+		return u.String(), true
+	}
+	return src, false
+}
+
+// rewriteURL applies the first matching rule to src.
+func rewriteURL(src string, rules []RewriteRule) string {
+	for _, r := range rules {
+		if rewritten, ok := r.rewrite(src); ok {
+			return rewritten
+		}
+	}
+	return src
+}
+
+// candidateURLs lists the URLs a package's dist may be downloaded from in the
+// order they should be tried: preferred mirrors, the dist URL itself and then
+// the remaining mirrors.
+func candidateURLs(p Package, rules []RewriteRule) []string {
+	var preferred, fallback []string
+	for _, m := range p.Distribution.Mirrors {
+		src := mirrorURL(m.URL, p)
+		if m.Preferred {
+			preferred = append(preferred, src)
+		} else {
+			fallback = append(fallback, src)
+		}
+	}
+
+	var (
+		candidates []string
+		seen       = make(map[string]bool)
+	)
+	for _, src := range append(append(preferred, p.Distribution.URL), fallback...) {
+		if src == "" {
+			continue
+		}
+		src = rewriteURL(src, rules)
+		if !seen[src] {
+			seen[src] = true
+			candidates = append(candidates, src)
+		}
+	}
+	return candidates
+}
+
+// mirrorURL expands the placeholders composer allows in dist mirror URLs.
+func mirrorURL(template string, p Package) string {
+	return strings.NewReplacer(
+		"%package%", p.Name,
+		"%version%", p.Version,
+		"%reference%", p.Distribution.Reference,
+		"%type%", p.Distribution.Type,
+	).Replace(template)
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandidateURLs(t *testing.T) {
+	rules := []RewriteRule{
+		{Prefix: "https://api.github.com/repos/", To: "https://artifacts.internal/github/"},
+		{Host: "codeload.github.com", To: "artifacts.internal"},
+		{Host: "github.com", To: "artifacts.internal"},
+	}
+	tests := map[string]struct {
+		dist       Distribution
+		rules      []RewriteRule
+		candidates []string
+	}{
+		"dist url alone": {
+			dist:       Distribution{URL: "https://api.github.com/repos/composer/semver/zipball/46d9139"},
+			candidates: []string{"https://api.github.com/repos/composer/semver/zipball/46d9139"},
+		},
+		"prefix rule": {
+			dist:       Distribution{URL: "https://api.github.com/repos/composer/semver/zipball/46d9139"},
+			rules:      rules,
+			candidates: []string{"https://artifacts.internal/github/composer/semver/zipball/46d9139"},
+		},
+		"host rule": {
+			dist:       Distribution{URL: "https://codeload.github.com/composer/semver/legacy.zip/46d9139"},
+			rules:      rules,
+			candidates: []string{"https://artifacts.internal/composer/semver/legacy.zip/46d9139"},
+		},
+		"only the first matching rule applies": {
+			dist: Distribution{URL: "https://github.com/composer/semver/archive/46d9139.zip"},
+			rules: []RewriteRule{
+				{Host: "github.com", To: "first.internal"},
+				{Host: "first.internal", To: "second.internal"},
+			},
+			candidates: []string{"https://first.internal/composer/semver/archive/46d9139.zip"},
+		},
+		"unmatched urls are untouched": {
+			dist:       Distribution{URL: "https://repo.example.org/dist/semver.zip"},
+			rules:      rules,
+			candidates: []string{"https://repo.example.org/dist/semver.zip"},
+		},
+		"preferred mirrors come first": {
+			dist: Distribution{
+				URL:       "https://api.github.com/repos/composer/semver/zipball/46d9139",
+				Type:      "zip",
+				Reference: "46d9139",
+				Mirrors: []Mirror{
+					{URL: "https://fallback.example.org/%package%/%version%.%type%"},
+					{URL: "https://mirror.example.org/%package%/%reference%.%type%", Preferred: true},
+				},
+			},
+			candidates: []string{
+				"https://mirror.example.org/composer/semver/46d9139.zip",
+				"https://api.github.com/repos/composer/semver/zipball/46d9139",
+				"https://fallback.example.org/composer/semver/1.5.0.zip",
+			},
+		},
+		"duplicate candidates are dropped": {
+			dist: Distribution{
+				URL:     "https://api.github.com/repos/composer/semver/zipball/46d9139",
+				Mirrors: []Mirror{{URL: "https://api.github.com/repos/%package%/zipball/46d9139", Preferred: true}},
+			},
+			candidates: []string{"https://api.github.com/repos/composer/semver/zipball/46d9139"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := Package{Name: "composer/semver", Version: "1.5.0", Distribution: tc.dist}
+			assert.Equal(t, tc.candidates, candidateURLs(p, tc.rules))
+		})
+	}
+}
+
+func TestDownloadFallsBackToNextMirror(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		switch r.Host {
+		case "proxy.test":
+			w.Write([]byte("archive"))
+		case "corrupt.test":
+			w.Write([]byte("corrupted"))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	path := tempPath(t)
+	defer os.Remove(path)
+	d := routedDownloader(t, server, DownloadOptions{
+		Retries:  1,
+		Rewrites: []RewriteRule{{Host: "github.test", To: "proxy.test"}},
+	})
+	err := d.download(path, Package{
+		Name: "composer/semver",
+		Distribution: Distribution{
+			URL:    "http://github.test/composer/semver.zip",
+			Shasum: "ebfb55f4432b592119a10592e4f26272cc72359e",
+			Mirrors: []Mirror{
+				{URL: "http://down.test/%package%.zip", Preferred: true},
+				{URL: "http://corrupt.test/%package%.zip", Preferred: true},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"down.test", "down.test", "corrupt.test", "proxy.test"}, hosts)
+}
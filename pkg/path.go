@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PathMode controls how packages with a "path" dist are placed in vendor.
+type PathMode string
+
+const (
+	// PathModeSymlink links vendor/<name> to the package's directory.
+	PathModeSymlink PathMode = "symlink"
+	// PathModeCopy copies the package's directory into vendor/<name>, for
+	// environments such as containers where symlinks break.
+	PathModeCopy PathMode = "copy"
+)
+
+// isPathDist reports whether a package is installed from a local directory
+// rather than an archive.
+func isPathDist(p Package) bool {
+	return p.Distribution.Type == "path"
+}
+
+// pathDistSource resolves the directory a path dist points at. Relative URLs
+// are resolved against the project directory.
+func pathDistSource(projectDir string, p Package) string {
+	src := filepath.FromSlash(p.Distribution.URL)
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(projectDir, src)
+	}
+	return src
+}
+
+// installPathPackage places a path dist at its install path within dir.
+// Symlinks are made relative to vendorDir, the final location of dir, so they
+// survive dir being renamed into place.
+func installPathPackage(dir, vendorDir, projectDir string, p Package, mode PathMode) error {
+	src := pathDistSource(projectDir, p)
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("unable to install %s from path: %w", p.Name, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("unable to install %s from path: %s is not a directory", p.Name, src)
+	}
+
+	packagePath, err := installPath(dir, p)
+	if err != nil {
+		return err
+	}
+	finalPath, err := installPath(vendorDir, p)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(packagePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case PathModeCopy:
+		err = copyDir(src, packagePath)
+	case PathModeSymlink, "":
+		var target string
+		target, err = filepath.Rel(filepath.Dir(finalPath), src)
+		if err == nil {
+			err = os.Symlink(target, packagePath)
+		}
+	default:
+		err = fmt.Errorf("unknown path mode %q", mode)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to install %s from path: %w", p.Name, err)
+	}
+	return nil
+}
+
+// copyDir recursively copies src to dst, preserving file modes and symlinks.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
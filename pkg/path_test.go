@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallPathPackages(t *testing.T) {
+	tests := map[string]struct {
+		mode    PathMode
+		url     func(root string) string
+		symlink bool
+	}{
+		"relative path is symlinked by default": {
+			url:     func(root string) string { return "../packages/foo" },
+			symlink: true,
+		},
+		"absolute path is symlinked": {
+			mode:    PathModeSymlink,
+			url:     func(root string) string { return filepath.Join(root, "packages", "foo") },
+			symlink: true,
+		},
+		"relative path is copied": {
+			mode: PathModeCopy,
+			url:  func(root string) string { return "../packages/foo" },
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "compote_path_")
+			assert.Nil(t, err)
+			defer os.RemoveAll(root)
+			src := filepath.Join(root, "packages", "foo")
+			assert.Nil(t, os.MkdirAll(filepath.Join(src, "bin"), 0755))
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "composer.json"), []byte(`{"name": "acme/foo"}`), 0644))
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "bin", "foo"), []byte("#!/usr/bin/env php\n"), 0755))
+
+			projectDir := filepath.Join(root, "app")
+			assert.Nil(t, os.MkdirAll(projectDir, 0755))
+			file, err := newLockfile(filepath.Join(projectDir, "composer.lock"), newLockfileOptions{skipLoading: true})
+			assert.Nil(t, err)
+			file.Packages = []Package{{
+				Name:         "acme/foo",
+				Distribution: Distribution{Type: "path", URL: tc.url(root)},
+			}}
+
+			assert.Nil(t, Install(file, InstallOptions{Quiet: true, PathMode: tc.mode, RequireChecksums: true}))
+			packagePath := filepath.Join(projectDir, "vendor", "acme", "foo")
+			info, err := os.Lstat(packagePath)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.symlink, info.Mode()&os.ModeSymlink != 0)
+			if tc.symlink {
+				target, err := os.Readlink(packagePath)
+				assert.Nil(t, err)
+				assert.False(t, filepath.IsAbs(target))
+			}
+			assert.FileExists(t, filepath.Join(packagePath, "composer.json"))
+			info, err = os.Stat(filepath.Join(packagePath, "bin", "foo"))
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		})
+	}
+}
+
+func TestInstallMissingPathPackage(t *testing.T) {
+	file, cleanup := newTestLockfile(t, Package{
+		Name:         "acme/missing",
+		Distribution: Distribution{Type: "path", URL: "packages/missing"},
+	})
+	defer cleanup()
+
+	err := Install(file, InstallOptions{Quiet: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "acme/missing")
+}
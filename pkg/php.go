@@ -0,0 +1,206 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+)
+
+// phpClasses returns the fully qualified names of the classes, interfaces,
+// traits and enums declared in the PHP source src, in the order they are
+// declared. Only code within PHP tags is considered; strings, heredocs and
+// comments are skipped.
+func phpClasses(src []byte) []string {
+	var (
+		tokens    = phpTokens(src)
+		namespace string
+		classes   []string
+	)
+	token := func(i int) string {
+		if i < 0 || i >= len(tokens) {
+			return ""
+		}
+		return tokens[i]
+	}
+	for i, tok := range tokens {
+		switch strings.ToLower(tok) {
+		case "namespace":
+			// namespace Foo\Bar; and namespace Foo\Bar { ... } declare a
+			// namespace, namespace { ... } returns to the global one. Other
+			// uses, such as namespace\func(), are a single token.
+			next := token(i + 1)
+			if next == "{" {
+				namespace = ""
+			} else if isPHPName(next) && (token(i+2) == ";" || token(i+2) == "{") {
+				namespace = strings.Trim(next, `\`)
+			}
+		case "class", "interface", "trait", "enum":
+			switch strings.ToLower(token(i - 1)) {
+			case "::", "->", "?->", "new", "function", "const":
+				// Foo::class, $obj->class, new class() and methods or
+				// constants named after the keyword declare nothing.
+				continue
+			}
+			name := token(i + 1)
+			if !isPHPName(name) || strings.Contains(name, `\`) {
+				continue
+			}
+			switch strings.ToLower(name) {
+			case "extends", "implements":
+				continue
+			}
+			if strings.EqualFold(tok, "enum") {
+				// enum is not reserved, so only enum Name {, enum Name: type
+				// and enum Name implements declare an enum.
+				after := strings.ToLower(token(i + 2))
+				if after != "{" && after != ":" && after != "implements" {
+					continue
+				}
+			}
+			if namespace != "" {
+				name = namespace + `\` + name
+			}
+			classes = append(classes, name)
+		}
+	}
+	return classes
+}
+
+// isPHPName reports whether tok is an identifier or qualified name.
+func isPHPName(tok string) bool {
+	return tok != "" && isPHPNameByte(tok[0]) && (tok[0] < '0' || tok[0] > '9')
+}
+
+func isPHPNameByte(c byte) bool {
+	return c == '_' || c == '\\' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// phpTokens splits the PHP code in src into names, variables and
+// punctuation, dropping whitespace, comments, strings and inline HTML.
+func phpTokens(src []byte) []string {
+	var tokens []string
+	i := 0
+	inPHP := false
+	for i < len(src) {
+		if !inPHP {
+			open := bytes.Index(src[i:], []byte("<?"))
+			if open < 0 {
+				break
+			}
+			i += open + 2
+			if i+3 <= len(src) && strings.EqualFold(string(src[i:i+3]), "php") {
+				i += 3
+			}
+			inPHP = true
+			continue
+		}
+
+		c := src[i]
+		switch {
+		case c == '?' && i+1 < len(src) && src[i+1] == '>':
+			inPHP = false
+			i += 2
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return tokens
+			}
+			i += 2 + end + 2
+		case c == '#' && !(i+1 < len(src) && src[i+1] == '['),
+			c == '/' && i+1 < len(src) && src[i+1] == '/':
+			// Line comments end at the end of the line or a closing tag.
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '?' && i+1 < len(src) && src[i+1] == '>' {
+					break
+				}
+				i++
+			}
+		case c == '\'' || c == '"' || c == '`':
+			i = skipPHPString(src, i)
+		case c == '<' && bytes.HasPrefix(src[i:], []byte("<<<")):
+			i = skipPHPHeredoc(src, i)
+		case c == '$' && i+1 < len(src) && isPHPNameByte(src[i+1]):
+			start := i
+			i++
+			for i < len(src) && isPHPNameByte(src[i]) && src[i] != '\\' {
+				i++
+			}
+			tokens = append(tokens, string(src[start:i]))
+		case isPHPNameByte(c):
+			start := i
+			for i < len(src) && isPHPNameByte(src[i]) {
+				i++
+			}
+			tokens = append(tokens, string(src[start:i]))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == ':' && i+1 < len(src) && src[i+1] == ':':
+			tokens = append(tokens, "::")
+			i += 2
+		case c == '-' && i+1 < len(src) && src[i+1] == '>':
+			tokens = append(tokens, "->")
+			i += 2
+		case c == '?' && bytes.HasPrefix(src[i:], []byte("?->")):
+			tokens = append(tokens, "?->")
+			i += 3
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipPHPString returns the offset just past the quoted string starting at
+// i, honoring backslash escapes.
+func skipPHPString(src []byte, i int) int {
+	quote := src[i]
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return i
+}
+
+// skipPHPHeredoc returns the offset just past the heredoc or nowdoc starting
+// at i. Its closing identifier may be indented, as PHP 7.3 allows.
+func skipPHPHeredoc(src []byte, i int) int {
+	j := i + 3
+	for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+		j++
+	}
+	if j < len(src) && (src[j] == '\'' || src[j] == '"') {
+		j++
+	}
+	start := j
+	for j < len(src) && isPHPNameByte(src[j]) && src[j] != '\\' {
+		j++
+	}
+	label := src[start:j]
+	if len(label) == 0 {
+		// Not a heredoc after all, such as a shift followed by a comparison.
+		return i + 3
+	}
+
+	for {
+		nl := bytes.IndexByte(src[j:], '\n')
+		if nl < 0 {
+			return len(src)
+		}
+		j += nl + 1
+		k := j
+		for k < len(src) && (src[k] == ' ' || src[k] == '\t') {
+			k++
+		}
+		if bytes.HasPrefix(src[k:], label) {
+			end := k + len(label)
+			if end >= len(src) || !isPHPNameByte(src[end]) {
+				return end
+			}
+		}
+	}
+}
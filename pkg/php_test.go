@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPHPClasses(t *testing.T) {
+	tests := map[string]struct {
+		src     string
+		classes []string
+	}{
+		"global class": {
+			src:     "<?php\nclass Foo {}\n",
+			classes: []string{"Foo"},
+		},
+		"every declaration": {
+			src: `<?php
+namespace Acme\Shapes;
+
+abstract class Shape {}
+final class Square extends Shape implements HasArea {}
+interface HasArea {}
+trait Named {}
+enum Suit: string { case Hearts = 'H'; }
+enum Colour { case Red; }
+enum Size implements HasArea {}
+`,
+			classes: []string{`Acme\Shapes\Shape`, `Acme\Shapes\Square`, `Acme\Shapes\HasArea`, `Acme\Shapes\Named`, `Acme\Shapes\Suit`, `Acme\Shapes\Colour`, `Acme\Shapes\Size`},
+		},
+		"braced namespaces": {
+			src: `<?php
+namespace Acme\One {
+    class A {}
+}
+namespace {
+    class B {}
+}
+namespace Acme\Two {
+    interface C {}
+}
+`,
+			classes: []string{`Acme\One\A`, "B", `Acme\Two\C`},
+		},
+		"keywords that declare nothing": {
+			src: `<?php
+namespace Acme;
+
+use Other\Thing;
+
+class Real
+{
+    const class_name = Thing::class;
+
+    public function enum() { return $this->class; }
+
+    public function make()
+    {
+        $class = new class() extends Thing {};
+        $enum = enum_exists('Foo');
+        namespace\helper();
+        return static::class . $obj?->class;
+    }
+}
+`,
+			classes: []string{`Acme\Real`},
+		},
+		"comments and strings": {
+			src: `<?php
+// class CommentedOut {}
+# interface Hashed {}
+/* trait Blocked {} */
+/**
+ * class InDocblock {}
+ */
+$a = 'class Single {}';
+$b = "class Double { \" class Escaped {} }";
+$c = <<<EOT
+class Heredoc {}
+EOT;
+$d = <<<'EOT'
+    class Nowdoc {}
+    EOT;
+#[Attribute]
+class Attributed {}
+`,
+			classes: []string{"Attributed"},
+		},
+		"inline html": {
+			src:     "class Html {}\n<?php class Code {} ?>\nclass MoreHtml {}\n<?php // class Comment ?> class Between <?php\nclass Last {}",
+			classes: []string{"Code", "Last"},
+		},
+		"case insensitive keywords": {
+			src:     "<?php\nNAMESPACE Acme;\nFinal Class Upper {}\n",
+			classes: []string{`Acme\Upper`},
+		},
+		"no php tag": {
+			src: "class NotPHP {}\n",
+		},
+		"unterminated comment": {
+			src:     "<?php\nclass Before {}\n/* class After {}",
+			classes: []string{"Before"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.classes, phpClasses([]byte(tc.src)))
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package pkg
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OperationType names the kind of change an Operation makes to vendor.
+type OperationType string
+
+const (
+	OperationInstall   OperationType = "install"
+	OperationUpgrade   OperationType = "upgrade"
+	OperationDowngrade OperationType = "downgrade"
+	OperationReinstall OperationType = "reinstall"
+	OperationRemove    OperationType = "remove"
+)
+
+// Operation is a single change an install makes to a package in vendor.
+type Operation struct {
+	Type          OperationType `json:"type"`
+	Package       string        `json:"package"`
+	FromVersion   string        `json:"from_version,omitempty"`
+	FromReference string        `json:"from_reference,omitempty"`
+	ToVersion     string        `json:"to_version,omitempty"`
+	ToReference   string        `json:"to_reference,omitempty"`
+	pkg           Package
+}
+
+func (o Operation) String() string {
+	switch o.Type {
+	case OperationInstall:
+		return fmt.Sprintf("Installing %s (%s)", o.Package, o.ToVersion)
+	case OperationUpgrade:
+		return fmt.Sprintf("Upgrading %s (%s => %s)", o.Package, o.FromVersion, o.ToVersion)
+	case OperationDowngrade:
+		return fmt.Sprintf("Downgrading %s (%s => %s)", o.Package, o.FromVersion, o.ToVersion)
+	case OperationReinstall:
+		return fmt.Sprintf("Reinstalling %s (%s %s => %s)", o.Package, o.ToVersion, shortReference(o.FromReference), shortReference(o.ToReference))
+	case OperationRemove:
+		return fmt.Sprintf("Removing %s (%s)", o.Package, o.FromVersion)
+	}
+	return string(o.Type) + " " + o.Package
+}
+
+// Plan lists the operations needed to bring vendor in line with a lockfile.
+// Install builds a Plan and executes it, and it can also be built on its own
+// to inspect what an install would do.
+type Plan struct {
+	Operations []Operation `json:"operations"`
+
+	projectDir string
+	vendorDir  string
+	packages   []Package
+	// root is the root package as it is autoloaded.
+	root Package
+	// incremental is false when vendor has no installed.json and is replaced
+	// wholesale.
+	incremental bool
+}
+
+// NewPlan compares the packages locked in file against the packages recorded
+// in vendor/composer/installed.json. Packages are unchanged when their name,
+// version and references match and their directory is still present in
+// vendor. Path dists are always reinstalled since their contents cannot be
+// compared.
+func NewPlan(file DependencyFile, withDev bool) (*Plan, error) {
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	installed, incremental, err := readInstalled(vendorDir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := file.RootPackage()
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		Operations:  []Operation{},
+		projectDir:  file.Dirpath(),
+		vendorDir:   vendorDir,
+		packages:    file.Dependencies(withDev),
+		root:        root.asPackage(withDev),
+		incremental: incremental,
+	}
+
+	current := make(map[string]Package)
+	for _, p := range installed {
+		current[p.Name] = p
+	}
+	wanted := make(map[string]bool)
+	for _, p := range plan.packages {
+		wanted[p.Name] = true
+		old, ok := current[p.Name]
+		if !ok {
+			plan.add(OperationInstall, Package{}, p)
+			continue
+		}
+		if !isPathDist(p) && sameRelease(old, p) {
+			if packagePath, err := installPath(vendorDir, p); err == nil {
+				if exists, _ := pathExists(packagePath); exists {
+					continue
+				}
+			}
+		}
+		switch c := compareVersions(old.Version, p.Version); {
+		case c < 0:
+			plan.add(OperationUpgrade, old, p)
+		case c > 0:
+			plan.add(OperationDowngrade, old, p)
+		default:
+			plan.add(OperationReinstall, old, p)
+		}
+	}
+
+	var removed []Package
+	for _, p := range installed {
+		if !wanted[p.Name] {
+			removed = append(removed, p)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name < removed[j].Name })
+	for _, p := range removed {
+		plan.add(OperationRemove, p, Package{})
+	}
+	return plan, nil
+}
+
+func (plan *Plan) add(t OperationType, from, to Package) {
+	op := Operation{Type: t, pkg: to}
+	if t == OperationRemove {
+		op.Package = from.Name
+		op.pkg = from
+	} else {
+		op.Package = to.Name
+		op.ToVersion = to.Version
+		op.ToReference = packageReference(to)
+	}
+	if from.Name != "" {
+		op.FromVersion = from.Version
+		op.FromReference = packageReference(from)
+	}
+	plan.Operations = append(plan.Operations, op)
+}
+
+// Empty reports whether vendor is already up to date.
+func (plan *Plan) Empty() bool {
+	return len(plan.Operations) == 0
+}
+
+// Count returns the number of operations of the given type.
+func (plan *Plan) Count(t OperationType) int {
+	n := 0
+	for _, op := range plan.Operations {
+		if op.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+func (plan *Plan) String() string {
+	if plan.Empty() {
+		return "Nothing to install, update or remove\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package operations: %d installs, %d upgrades, %d downgrades, %d reinstalls, %d removals\n",
+		plan.Count(OperationInstall),
+		plan.Count(OperationUpgrade),
+		plan.Count(OperationDowngrade),
+		plan.Count(OperationReinstall),
+		plan.Count(OperationRemove),
+	)
+	for _, op := range plan.Operations {
+		fmt.Fprintf(&b, "  - %s\n", op)
+	}
+	return b.String()
+}
+
+// installs returns the packages the plan downloads or links into vendor.
+func (plan *Plan) installs() []Package {
+	var packages []Package
+	for _, op := range plan.Operations {
+		if op.Type != OperationRemove {
+			packages = append(packages, op.pkg)
+		}
+	}
+	return packages
+}
+
+// removals returns the packages the plan deletes from vendor.
+func (plan *Plan) removals() []Package {
+	var packages []Package
+	for _, op := range plan.Operations {
+		if op.Type == OperationRemove {
+			packages = append(packages, op.pkg)
+		}
+	}
+	return packages
+}
+
+// sameRelease reports whether two packages refer to exactly the same code.
+func sameRelease(a, b Package) bool {
+	return a.Version == b.Version &&
+		a.Distribution.Reference == b.Distribution.Reference &&
+		a.Source.Reference == b.Source.Reference
+}
+
+// packageReference returns the reference a package is installed from.
+func packageReference(p Package) string {
+	if p.Distribution.Reference != "" {
+		return p.Distribution.Reference
+	}
+	return p.Source.Reference
+}
+
+func shortReference(reference string) string {
+	if len(reference) > 10 {
+		return reference[:10]
+	}
+	return reference
+}
+
+// stabilities orders the stability suffixes composer recognises.
+var stabilities = map[string]int{"dev": 0, "alpha": 1, "a": 1, "beta": 2, "b": 2, "rc": 3, "stable": 4, "patch": 5, "pl": 5, "p": 5}
+
+// compareVersions compares two package versions, returning -1, 0 or 1.
+// Numeric segments are compared in order followed by stability, so that
+// 1.0.0-beta1 < 1.0.0-RC1 < 1.0.0. Branch versions such as dev-main cannot
+// be ordered and compare as lower than any release.
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	aBranch, bBranch := strings.HasPrefix(a, "dev-"), strings.HasPrefix(b, "dev-")
+	switch {
+	case aBranch && bBranch:
+		return 0
+	case aBranch:
+		return -1
+	case bBranch:
+		return 1
+	}
+
+	aNums, aStability, aBuild := splitVersion(a)
+	bNums, bStability, bBuild := splitVersion(b)
+	for i := 0; i < len(aNums) || i < len(bNums); i++ {
+		var x, y int
+		if i < len(aNums) {
+			x = aNums[i]
+		}
+		if i < len(bNums) {
+			y = bNums[i]
+		}
+		if x != y {
+			return sign(x - y)
+		}
+	}
+	if aStability != bStability {
+		return sign(aStability - bStability)
+	}
+	return sign(aBuild - bBuild)
+}
+
+// splitVersion breaks a version such as v1.2.3-RC2 into its numeric
+// segments, stability and stability build number.
+func splitVersion(version string) ([]int, int, int) {
+	version = strings.TrimPrefix(strings.ToLower(version), "v")
+	stability, build := stabilities["stable"], 0
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		suffix := strings.Trim(version[i+1:], ".-")
+		version = version[:i]
+		name := strings.TrimRight(suffix, "0123456789.")
+		if s, ok := stabilities[name]; ok {
+			stability = s
+			build, _ = strconv.Atoi(strings.Trim(strings.TrimPrefix(suffix, name), "."))
+		}
+	}
+	var nums []int
+	for _, segment := range strings.Split(version, ".") {
+		// Wildcard segments such as 1.x-dev sort above any number.
+		n, err := strconv.Atoi(segment)
+		if err != nil {
+			n = int(^uint(0) >> 1)
+		}
+		nums = append(nums, n)
+	}
+	return nums, stability, build
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlan(t *testing.T) {
+	release := func(name, version, reference string) Package {
+		return Package{Name: name, Version: version, Distribution: Distribution{Type: "zip", Reference: reference}}
+	}
+	installed := []Package{
+		release("acme/unchanged", "1.0.0", "aaa"),
+		release("acme/upgraded", "1.0.0", "bbb"),
+		release("acme/downgraded", "2.0.0", "ccc"),
+		release("acme/rereferenced", "dev-main", "ddd"),
+		release("acme/deleted", "1.0.0", "eee"),
+		release("gone/removed", "1.0.0", "fff"),
+	}
+	file, cleanup := newTestLockfile(t,
+		release("acme/unchanged", "1.0.0", "aaa"),
+		release("acme/upgraded", "1.1.0", "bbb1"),
+		release("acme/downgraded", "1.9.9", "ccc1"),
+		release("acme/rereferenced", "dev-main", "ddd1"),
+		release("acme/deleted", "1.0.0", "eee"),
+		release("acme/added", "1.0.0", "ggg"),
+	)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	assert.Nil(t, writeInstalled(vendorDir, installed))
+	for _, p := range installed {
+		if p.Name != "acme/deleted" {
+			assert.Nil(t, os.MkdirAll(filepath.Join(vendorDir, p.Name), 0755))
+		}
+	}
+
+	plan, err := NewPlan(file, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{
+		{Type: OperationUpgrade, Package: "acme/upgraded", FromVersion: "1.0.0", FromReference: "bbb", ToVersion: "1.1.0", ToReference: "bbb1"},
+		{Type: OperationDowngrade, Package: "acme/downgraded", FromVersion: "2.0.0", FromReference: "ccc", ToVersion: "1.9.9", ToReference: "ccc1"},
+		{Type: OperationReinstall, Package: "acme/rereferenced", FromVersion: "dev-main", FromReference: "ddd", ToVersion: "dev-main", ToReference: "ddd1"},
+		{Type: OperationReinstall, Package: "acme/deleted", FromVersion: "1.0.0", FromReference: "eee", ToVersion: "1.0.0", ToReference: "eee"},
+		{Type: OperationInstall, Package: "acme/added", ToVersion: "1.0.0", ToReference: "ggg"},
+		{Type: OperationRemove, Package: "gone/removed", FromVersion: "1.0.0", FromReference: "fff"},
+	}, withoutPackages(plan.Operations))
+
+	assert.Equal(t, `Package operations: 1 installs, 1 upgrades, 1 downgrades, 2 reinstalls, 1 removals
+  - Upgrading acme/upgraded (1.0.0 => 1.1.0)
+  - Downgrading acme/downgraded (2.0.0 => 1.9.9)
+  - Reinstalling acme/rereferenced (dev-main ddd => ddd1)
+  - Reinstalling acme/deleted (1.0.0 eee => eee)
+  - Installing acme/added (1.0.0)
+  - Removing gone/removed (1.0.0)
+`, plan.String())
+
+	planJSON, err := json.Marshal(plan)
+	assert.Nil(t, err)
+	var decoded map[string][]map[string]string
+	assert.Nil(t, json.Unmarshal(planJSON, &decoded))
+	assert.Equal(t, map[string]string{"type": "install", "package": "acme/added", "to_version": "1.0.0", "to_reference": "ggg"}, decoded["operations"][4])
+}
+
+func TestNewPlanWithoutInstalled(t *testing.T) {
+	file, cleanup := newTestLockfile(t, Package{Name: "composer/semver", Version: "1.5.0"})
+	defer cleanup()
+
+	plan, err := NewPlan(file, true)
+	assert.Nil(t, err)
+	assert.False(t, plan.incremental)
+	assert.Equal(t, 1, plan.Count(OperationInstall))
+
+	file.Packages = nil
+	plan, err = NewPlan(file, true)
+	assert.Nil(t, err)
+	assert.True(t, plan.Empty())
+	planJSON, err := json.Marshal(plan)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"operations": []}`, string(planJSON))
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"v2.0.0", "1.9.9", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.0-beta1", "1.0.0", -1},
+		{"1.0.0-beta2", "1.0.0-beta10", -1},
+		{"1.0.0-RC1", "1.0.0-beta3", 1},
+		{"1.0.0-alpha", "1.0.0-dev", 1},
+		{"1.x-dev", "1.5.0", 1},
+		{"dev-main", "1.0.0", -1},
+		{"dev-main", "dev-feature", 0},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, compareVersions(tc.a, tc.b), tc.a+" vs "+tc.b)
+	}
+}
+
+// withoutPackages strips the unexported package from operations so they can
+// be compared by their exported fields alone.
+func withoutPackages(ops []Operation) []Operation {
+	stripped := make([]Operation, len(ops))
+	for i, op := range ops {
+		op.pkg = Package{}
+		stripped[i] = op
+	}
+	return stripped
+}
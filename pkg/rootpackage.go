@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// rootPackageName is the name composer gives a root package without one.
+const rootPackageName = "__root__"
+
+// RootPackage is the project described by the composer.json next to a
+// lockfile. Only what compote needs to install and autoload the project is
+// read.
+type RootPackage struct {
+	Name        string   `json:"name"`
+	Autoload    Autoload `json:"autoload"`
+	AutoloadDev Autoload `json:"autoload-dev"`
+	IncludePath []string `json:"include-path"`
+}
+
+// loadRootPackage reads the composer.json in dir, returning nil when the
+// project does not have one.
+func loadRootPackage(dir string) (*RootPackage, error) {
+	path := filepath.Join(dir, "composer.json")
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	root := &RootPackage{}
+	if err := json.Unmarshal(contents, root); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if root.Name == "" {
+		root.Name = rootPackageName
+	}
+	return root, nil
+}
+
+// asPackage returns the root package as it is autoloaded, with its
+// autoload-dev section merged in when withDev is set. A missing root package
+// autoloads nothing.
+func (r *RootPackage) asPackage(withDev bool) Package {
+	if r == nil {
+		return Package{Name: rootPackageName}
+	}
+	p := Package{Name: r.Name, Autoload: r.Autoload, IncludePath: r.IncludePath}
+	if withDev {
+		p.Autoload = mergeAutoload(r.Autoload, r.AutoloadDev)
+	}
+	return p
+}
+
+// mergeAutoload combines two autoload sections, with the paths of a coming
+// before those of b.
+func mergeAutoload(a, b Autoload) Autoload {
+	merged := Autoload{
+		Classmap:            append(append([]string{}, a.Classmap...), b.Classmap...),
+		ExcludeFromClassmap: append(append([]string{}, a.ExcludeFromClassmap...), b.ExcludeFromClassmap...),
+		Files:               append(append([]string{}, a.Files...), b.Files...),
+	}
+	merge := func(a, b FlexPSR) FlexPSR {
+		mappings := a.Mappings()
+		for prefix, paths := range b.Mappings() {
+			mappings[prefix] = append(mappings[prefix], paths...)
+		}
+		return FlexPSR{Multiple: &mappings}
+	}
+	merged.PSR0 = merge(a.PSR0, b.PSR0)
+	merged.PSR4 = merge(a.PSR4, b.PSR4)
+	return merged
+}
@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRootPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compote_root_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	root, err := loadRootPackage(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, root)
+
+	writeFiles(t, dir, map[string]string{"composer.json": `{
+    "name": "acme/project",
+    "autoload": {"psr-4": {"App\\": "app/"}, "files": ["app/helpers.php"]},
+    "autoload-dev": {"psr-4": {"Tests\\": ["tests/"]}}
+}`})
+	root, err = loadRootPackage(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "acme/project", root.Name)
+	assert.Equal(t, map[string][]string{`App\`: {"app/"}}, root.Autoload.PSR4.Mappings())
+	assert.Equal(t, []string{"app/helpers.php"}, root.Autoload.Files)
+	assert.Equal(t, map[string][]string{`Tests\`: {"tests/"}}, root.AutoloadDev.PSR4.Mappings())
+
+	writeFiles(t, dir, map[string]string{"composer.json": `{"require": `})
+	_, err = loadRootPackage(dir)
+	assert.NotNil(t, err)
+
+	// testdata/composer.json has neither a name nor any autoloading.
+	file, err := LoadFile("../testdata")
+	assert.Nil(t, err)
+	root, err = file.RootPackage()
+	assert.Nil(t, err)
+	if assert.NotNil(t, root) {
+		assert.Equal(t, rootPackageName, root.Name)
+	}
+
+	// A composer.json that cannot be read only fails once it is needed.
+	writeFiles(t, dir, map[string]string{
+		"composer.json": `{"require": `,
+		"composer.lock": `{"packages": [], "packages-dev": []}`,
+	})
+	file, err = LoadFile(dir)
+	assert.Nil(t, err)
+	_, err = file.RootPackage()
+	assert.NotNil(t, err)
+}
+
+func TestRootPackageAsPackage(t *testing.T) {
+	root := &RootPackage{
+		Name: "acme/project",
+		Autoload: Autoload{
+			PSR4:     FlexPSR{Single: &map[string]string{`App\`: "app/"}},
+			Classmap: []string{"database/"},
+		},
+		AutoloadDev: Autoload{
+			PSR4:  FlexPSR{Multiple: &map[string][]string{`App\`: {"tests/app/"}, `Tests\`: {"tests/"}}},
+			PSR0:  FlexPSR{Single: &map[string]string{"Legacy_": "tests/legacy/"}},
+			Files: []string{"tests/helpers.php"},
+		},
+	}
+
+	p := root.asPackage(false)
+	assert.Equal(t, "acme/project", p.Name)
+	assert.Equal(t, root.Autoload, p.Autoload)
+
+	p = root.asPackage(true)
+	assert.Equal(t, map[string][]string{`App\`: {"app/", "tests/app/"}, `Tests\`: {"tests/"}}, p.Autoload.PSR4.Mappings())
+	assert.Equal(t, map[string][]string{"Legacy_": {"tests/legacy/"}}, p.Autoload.PSR0.Mappings())
+	assert.Equal(t, []string{"database/"}, p.Autoload.Classmap)
+	assert.Equal(t, []string{"tests/helpers.php"}, p.Autoload.Files)
+	// Merging leaves the sections it was built from alone.
+	assert.Equal(t, map[string][]string{`App\`: {"app/"}}, root.Autoload.PSR4.Mappings())
+
+	var missing *RootPackage
+	assert.Equal(t, Package{Name: rootPackageName}, missing.asPackage(true))
+}
+
+func TestWriteAutoloadRootPackage(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	projectDir := file.Dirpath()
+	vendorDir := filepath.Join(projectDir, "vendor")
+	packages := []Package{
+		{
+			Name:     "acme/app",
+			Require:  map[string]string{"acme/helpers": "^1.0"},
+			Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\`: "src/"}}},
+		},
+		{
+			Name:     "acme/helpers",
+			Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\`: "lib/"}}, Files: []string{"functions.php"}},
+		},
+	}
+	root := (&RootPackage{
+		Name:     "acme/project",
+		Autoload: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Acme\`: "app/"}}, Files: []string{"app/helpers.php"}},
+	}).asPackage(false)
+
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, packages, root, AutoloadOptions{}, true))
+	read := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", name))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+	// The root package is searched first and its files are included last.
+	assert.Contains(t, read("autoload_psr4.php"), `'Acme\\' => array($baseDir . '/app', $vendorDir . '/acme/app/src', $vendorDir . '/acme/helpers/lib'),`)
+	assert.Contains(t, read("autoload_files.php"), `return array(
+    '`+fileIdentifier("acme/helpers", "functions.php")+`' => $vendorDir . '/acme/helpers/functions.php',
+    '`+fileIdentifier("acme/project", "app/helpers.php")+`' => $baseDir . '/app/helpers.php',
+);`)
+	assert.Contains(t, read("autoload_static.php"), `0 => __DIR__ . '/../..' . '/app',`)
+}
+
+func TestWriteAutoloadRootExcludes(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	// Dirpath keeps a trailing separator, which must not break excludes.
+	projectDir := file.Dirpath()
+	writeFiles(t, projectDir, map[string]string{
+		"database/Seeder.php":         "<?php class Seeder {}",
+		"database/factories/User.php": "<?php class UserFactory {}",
+	})
+	root := Package{Name: "acme/project", Autoload: Autoload{Classmap: []string{"database/"}, ExcludeFromClassmap: []string{"database/factories/"}}}
+
+	vendorDir := filepath.Join(projectDir, "vendor")
+	assert.Nil(t, writeAutoload(vendorDir, projectDir, nil, root, AutoloadOptions{}, true))
+	contents, err := ioutil.ReadFile(filepath.Join(vendorDir, "composer", "autoload_classmap.php"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), `'Seeder' => $baseDir . '/database/Seeder.php',`)
+	assert.NotContains(t, string(contents), "UserFactory")
+}
+
+func TestInstallAutoloadsRootPackage(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	file.root = &RootPackage{
+		Name:        "acme/project",
+		Autoload:    Autoload{PSR4: FlexPSR{Single: &map[string]string{`App\`: "app/"}}},
+		AutoloadDev: Autoload{PSR4: FlexPSR{Single: &map[string]string{`Tests\`: "tests/"}}},
+	}
+	psr4 := func() string {
+		contents, err := ioutil.ReadFile(filepath.Join(file.Dirpath(), "vendor", "composer", "autoload_psr4.php"))
+		assert.Nil(t, err)
+		return string(contents)
+	}
+
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+	assert.Contains(t, psr4(), `'App\\' => array($baseDir . '/app'),`)
+	assert.Contains(t, psr4(), `'Tests\\' => array($baseDir . '/tests'),`)
+
+	assert.Nil(t, Install(file, InstallOptions{Quiet: true, SkipDev: true}))
+	assert.Contains(t, psr4(), `'App\\' => array($baseDir . '/app'),`)
+	assert.NotContains(t, psr4(), `Tests`)
+}
@@ -0,0 +1,311 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// snapshotDirName is the directory within the project that holds the vendor
+// contents replaced by the last install.
+const snapshotDirName = ".compote_rollback"
+
+// backupDirName is the directory within the project that holds the vendor
+// contents replaced by an install that is still running. It only outlives
+// the install when the install is interrupted, and is then restored by the
+// next Install or Rollback.
+const backupDirName = ".compote_backup"
+
+// vendorMetadata lists the vendor entries that describe the installed
+// packages as a whole. Incremental installs save a copy of them alongside the
+// packages they replace.
+var vendorMetadata = []string{"autoload.php", "composer"}
+
+// ErrNoSnapshot is returned by Rollback when no previous vendor is saved.
+var ErrNoSnapshot = errors.New("no previous vendor directory to roll back to")
+
+// snapshot records how to undo an install. A full snapshot holds the entire
+// previous vendor directory, or nothing when there was none. Otherwise it
+// holds only the packages and metadata an incremental install replaced.
+// The record is saved before vendor is touched, so packages it lists may
+// not have been moved into the snapshot yet.
+type snapshot struct {
+	dir string
+
+	Full bool `json:"full"`
+	// Previous is set when a vendor directory existed before a full install.
+	Previous bool `json:"previous,omitempty"`
+	// Replaced lists the packages moved into the snapshot.
+	Replaced []string `json:"replaced,omitempty"`
+	// Added lists the packages that did not exist before the install.
+	Added []string `json:"added,omitempty"`
+}
+
+func newSnapshot(projectDir string, full bool) (*snapshot, error) {
+	dir := filepath.Join(projectDir, backupDirName)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &snapshot{dir: dir, Full: full}, nil
+}
+
+// readSnapshot reads the snapshot saved in dir.
+func readSnapshot(dir string) (*snapshot, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		return nil, err
+	}
+	s := &snapshot{dir: dir}
+	if err := json.Unmarshal(contents, s); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", dir, err)
+	}
+	return s, nil
+}
+
+// recoverInstall restores the vendor directory of an install that was
+// interrupted before it finished, reporting whether there was one.
+func recoverInstall(projectDir string) (bool, error) {
+	dir := filepath.Join(projectDir, backupDirName)
+	if !exists(dir) {
+		return false, nil
+	}
+	s, err := readSnapshot(dir)
+	if os.IsNotExist(err) {
+		// The install stopped before it saved anything, so vendor is intact.
+		return true, os.RemoveAll(dir)
+	} else if err != nil {
+		return true, err
+	}
+	return true, s.restore(filepath.Join(projectDir, "vendor"))
+}
+
+// Rollback restores the vendor directory saved by the last install, undoing
+// it. The snapshot is consumed, so a second Rollback returns ErrNoSnapshot.
+// An install that was interrupted is undone first, on its own.
+func Rollback(file DependencyFile) error {
+	recovered, err := recoverInstall(file.Dirpath())
+	if recovered || err != nil {
+		return err
+	}
+
+	dir := filepath.Join(file.Dirpath(), snapshotDirName)
+	s, err := readSnapshot(dir)
+	if os.IsNotExist(err) {
+		return ErrNoSnapshot
+	} else if err != nil {
+		return err
+	}
+	return s.restore(filepath.Join(file.Dirpath(), "vendor"))
+}
+
+func (s *snapshot) vendorDir() string {
+	return filepath.Join(s.dir, "vendor")
+}
+
+// save writes the snapshot record, replacing it atomically.
+func (s *snapshot) save() error {
+	contents, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, "snapshot.json")
+	err = ioutil.WriteFile(path+".tmp", append(contents, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// swap moves any existing vendorDir into the snapshot and dir into its
+// place. The previous vendor is restored and the snapshot is discarded on
+// failure.
+func (s *snapshot) swap(dir, vendorDir string) error {
+	// dir was made by TempDir, which leaves it readable only by its owner.
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(s.dir)
+		return err
+	}
+	s.Previous = exists(vendorDir)
+	if err := s.save(); err != nil {
+		os.RemoveAll(s.dir)
+		return err
+	}
+	if s.Previous {
+		if err := os.Rename(vendorDir, s.vendorDir()); err != nil {
+			os.RemoveAll(s.dir)
+			return err
+		}
+	}
+	if err := os.Rename(dir, vendorDir); err != nil {
+		s.restore(vendorDir)
+		return err
+	}
+	return nil
+}
+
+// replace moves the previous versions of installs and removals into the
+// snapshot and the new installs from dir into vendorDir, leaving every other
+// package in place. The previous packages are restored and the snapshot is
+// discarded on failure.
+func (s *snapshot) replace(dir, vendorDir string, installs, removals []Package) error {
+	for i, p := range append(append([]Package{}, installs...), removals...) {
+		if exists(filepath.Join(vendorDir, filepath.FromSlash(p.Name))) {
+			s.Replaced = append(s.Replaced, p.Name)
+		} else if i < len(installs) {
+			s.Added = append(s.Added, p.Name)
+		}
+	}
+	if err := s.save(); err != nil {
+		os.RemoveAll(s.dir)
+		return err
+	}
+
+	for _, name := range vendorMetadata {
+		src := filepath.Join(vendorDir, name)
+		info, err := os.Lstat(src)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			os.RemoveAll(s.dir)
+			return err
+		}
+		// Copy into a scratch path first so that an interrupted copy is
+		// never restored over the original.
+		partial := filepath.Join(s.dir, "partial")
+		if info.IsDir() {
+			err = copyDir(src, partial)
+		} else {
+			err = copyFile(src, partial, info.Mode().Perm())
+		}
+		if err == nil {
+			err = os.MkdirAll(s.vendorDir(), 0755)
+		}
+		if err == nil {
+			err = os.Rename(partial, filepath.Join(s.vendorDir(), name))
+		}
+		if err != nil {
+			// Nothing has been moved yet, so only the snapshot is discarded.
+			os.RemoveAll(s.dir)
+			return err
+		}
+	}
+
+	for _, name := range s.Replaced {
+		backupPath := filepath.Join(s.vendorDir(), filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(backupPath), 0755)
+		if err == nil {
+			err = os.Rename(filepath.Join(vendorDir, filepath.FromSlash(name)), backupPath)
+		}
+		if err != nil {
+			s.restore(vendorDir)
+			return err
+		}
+	}
+
+	for _, p := range installs {
+		packagePath := filepath.Join(vendorDir, filepath.FromSlash(p.Name))
+		err := os.MkdirAll(filepath.Dir(packagePath), 0755)
+		if err == nil {
+			err = os.Rename(filepath.Join(dir, filepath.FromSlash(p.Name)), packagePath)
+		}
+		if err != nil {
+			s.restore(vendorDir)
+			return fmt.Errorf("unable to move %s into place: %w", p.Name, err)
+		}
+	}
+	for _, p := range removals {
+		removeEmptyParent(vendorDir, p.Name)
+	}
+	return nil
+}
+
+// commit keeps the snapshot as the one Rollback restores, replacing any
+// snapshot saved by an earlier install.
+func (s *snapshot) commit(projectDir string) error {
+	path := filepath.Join(projectDir, snapshotDirName)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	return os.Rename(s.dir, path)
+}
+
+// restore puts the snapshot's contents back into vendorDir and discards the
+// snapshot. Only what was moved into the snapshot is put back, so a snapshot
+// left by an interrupted install is restored as far as it got.
+func (s *snapshot) restore(vendorDir string) error {
+	if s.Full {
+		if s.Previous && !exists(s.vendorDir()) {
+			// The previous vendor was never moved aside.
+			return os.RemoveAll(s.dir)
+		}
+		if exists(vendorDir) {
+			discard := filepath.Join(s.dir, "discard")
+			if err := os.Rename(vendorDir, discard); err != nil {
+				return err
+			}
+		}
+		if exists(s.vendorDir()) {
+			if err := os.Rename(s.vendorDir(), vendorDir); err != nil {
+				return err
+			}
+		}
+		return os.RemoveAll(s.dir)
+	}
+
+	for _, name := range s.Added {
+		err := os.RemoveAll(filepath.Join(vendorDir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range s.Replaced {
+		backupPath := filepath.Join(s.vendorDir(), filepath.FromSlash(name))
+		if !exists(backupPath) {
+			continue
+		}
+		packagePath := filepath.Join(vendorDir, filepath.FromSlash(name))
+		err := os.RemoveAll(packagePath)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(packagePath), 0755)
+		}
+		if err == nil {
+			err = os.Rename(backupPath, packagePath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range vendorMetadata {
+		backupPath := filepath.Join(s.vendorDir(), name)
+		if !exists(backupPath) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(vendorDir, name)); err != nil {
+			return err
+		}
+		if err := os.Rename(backupPath, filepath.Join(vendorDir, name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.Added {
+		removeEmptyParent(vendorDir, name)
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// removeEmptyParent drops the vendor namespace of name when it no longer
+// holds any packages. Removing a directory that is not empty fails and is
+// ignored.
+func removeEmptyParent(vendorDir, name string) {
+	os.Remove(filepath.Dir(filepath.Join(vendorDir, filepath.FromSlash(name))))
+}
+
+// exists reports whether path exists without following a final symlink, so
+// that dangling path package links are still seen.
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,225 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipArchive(t, "package-root/", map[string]string{"composer.json": r.URL.Path}))
+	}))
+	defer server.Close()
+	release := func(name, reference string) Package {
+		return Package{
+			Name:         name,
+			Version:      "1.0.0",
+			Distribution: Distribution{Type: "zip", URL: server.URL + "/" + name + "/" + reference, Reference: reference},
+		}
+	}
+	contents := func(t *testing.T, path string) string {
+		contents, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		return string(contents)
+	}
+
+	t.Run("full", func(t *testing.T) {
+		file, cleanup := newTestLockfile(t, release("acme/a", "aaa"))
+		defer cleanup()
+		vendorDir := filepath.Join(file.Dirpath(), "vendor")
+		assert.Nil(t, os.MkdirAll(vendorDir, 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(vendorDir, "legacy"), []byte("legacy"), 0644))
+
+		assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+		assert.FileExists(t, filepath.Join(vendorDir, "acme", "a", "composer.json"))
+		assert.False(t, exists(filepath.Join(vendorDir, "legacy")))
+
+		assert.Nil(t, Rollback(file))
+		assert.Equal(t, "legacy", contents(t, filepath.Join(vendorDir, "legacy")))
+		assert.False(t, exists(filepath.Join(vendorDir, "acme")))
+		assert.Equal(t, ErrNoSnapshot, Rollback(file))
+	})
+
+	t.Run("without a previous vendor", func(t *testing.T) {
+		file, cleanup := newTestLockfile(t, release("acme/a", "aaa"))
+		defer cleanup()
+
+		assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+		info, err := os.Stat(filepath.Join(file.Dirpath(), "vendor"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, os.ModeDir|0755, info.Mode())
+		}
+		assert.Nil(t, Rollback(file))
+		assert.False(t, exists(filepath.Join(file.Dirpath(), "vendor")))
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		file, cleanup := newTestLockfile(t, release("acme/a", "aaa"), release("acme/b", "bbb"))
+		defer cleanup()
+		vendorDir := filepath.Join(file.Dirpath(), "vendor")
+		assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+		previous := file.Packages
+
+		file.Packages = []Package{release("acme/a", "aaa1"), release("other/c", "ccc")}
+		assert.Nil(t, Install(file, InstallOptions{Quiet: true}))
+		assert.Equal(t, "/acme/a/aaa1", contents(t, filepath.Join(vendorDir, "acme", "a", "composer.json")))
+		assert.False(t, exists(filepath.Join(vendorDir, "acme", "b")))
+
+		assert.Nil(t, Rollback(file))
+		assert.Equal(t, "/acme/a/aaa", contents(t, filepath.Join(vendorDir, "acme", "a", "composer.json")))
+		assert.Equal(t, "/acme/b/bbb", contents(t, filepath.Join(vendorDir, "acme", "b", "composer.json")))
+		assert.False(t, exists(filepath.Join(vendorDir, "other")))
+		installed, ok, err := readInstalled(vendorDir)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, previous, installed)
+		assert.False(t, exists(filepath.Join(file.Dirpath(), snapshotDirName)))
+	})
+}
+
+func TestSnapshotRestoresOnFailure(t *testing.T) {
+	file, cleanup := newTestLockfile(t)
+	defer cleanup()
+	vendorDir := filepath.Join(file.Dirpath(), "vendor")
+	existing := filepath.Join(vendorDir, "acme", "a", "composer.json")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(existing), 0755))
+	assert.Nil(t, ioutil.WriteFile(existing, []byte("existing"), 0644))
+	assert.Nil(t, writeInstalled(vendorDir, []Package{{Name: "acme/a"}}))
+
+	// The staging dir holds neither package, so moving them into place fails
+	// after acme/a has already been moved aside.
+	dir, err := ioutil.TempDir(file.Dirpath(), ".compote_")
+	assert.Nil(t, err)
+	backup, err := newSnapshot(file.Dirpath(), false)
+	assert.Nil(t, err)
+	err = backup.replace(dir, vendorDir, []Package{{Name: "acme/a"}, {Name: "acme/b"}}, nil)
+	assert.NotNil(t, err)
+
+	assert.FileExists(t, existing)
+	assert.False(t, exists(filepath.Join(vendorDir, "acme", "b")))
+	assert.False(t, exists(backup.dir))
+}
+
+func TestSnapshotCleanupOnFailure(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "compote_snapshot_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectDir)
+	vendorDir := filepath.Join(projectDir, "vendor")
+	assert.Nil(t, os.MkdirAll(filepath.Join(vendorDir, "composer"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(vendorDir, "autoload.php"), []byte("<?php"), 0644))
+
+	// A file where the snapshot's vendor directory belongs makes saving the
+	// vendor metadata fail.
+	s, err := newSnapshot(projectDir, false)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(s.vendorDir(), nil, 0644))
+	assert.NotNil(t, s.replace(filepath.Join(projectDir, "new"), vendorDir, nil, nil))
+	assert.False(t, exists(s.dir))
+	assert.FileExists(t, filepath.Join(vendorDir, "autoload.php"))
+
+	// A non-empty directory there makes moving the vendor directory fail.
+	assert.Nil(t, os.MkdirAll(filepath.Join(projectDir, "new"), 0700))
+	s, err = newSnapshot(projectDir, true)
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Join(s.vendorDir(), "taken"), 0755))
+	assert.NotNil(t, s.swap(filepath.Join(projectDir, "new"), vendorDir))
+	assert.False(t, exists(s.dir))
+	assert.FileExists(t, filepath.Join(vendorDir, "autoload.php"))
+}
+
+func TestRecoverInterruptedInstall(t *testing.T) {
+	setup := func(t *testing.T) (string, string, func()) {
+		projectDir, err := ioutil.TempDir("", "compote_snapshot_")
+		assert.Nil(t, err)
+		vendorDir := filepath.Join(projectDir, "vendor")
+		writeFiles(t, vendorDir, map[string]string{
+			"acme/a/composer.json":       "a",
+			"acme/b/composer.json":       "b",
+			"composer/installed.json":    "[]",
+			"autoload.php":               "<?php",
+			"../.compote_rollback/x.txt": "older install",
+		})
+		return projectDir, vendorDir, func() { os.RemoveAll(projectDir) }
+	}
+	assertRestored := func(t *testing.T, projectDir, vendorDir string) {
+		for path, contents := range map[string]string{"acme/a/composer.json": "a", "acme/b/composer.json": "b", "autoload.php": "<?php"} {
+			actual, err := ioutil.ReadFile(filepath.Join(vendorDir, path))
+			if assert.Nil(t, err) {
+				assert.Equal(t, contents, string(actual))
+			}
+		}
+		assert.False(t, exists(filepath.Join(vendorDir, "acme", "c")))
+		assert.False(t, exists(filepath.Join(projectDir, backupDirName)))
+		// The snapshot of the install before is kept for a later Rollback.
+		assert.FileExists(t, filepath.Join(projectDir, snapshotDirName, "x.txt"))
+	}
+
+	t.Run("full install stopped between renames", func(t *testing.T) {
+		projectDir, vendorDir, cleanup := setup(t)
+		defer cleanup()
+		s, err := newSnapshot(projectDir, true)
+		assert.Nil(t, err)
+		s.Previous = true
+		assert.Nil(t, s.save())
+		assert.Nil(t, os.Rename(vendorDir, s.vendorDir()))
+
+		file, err := newLockfile(filepath.Join(projectDir, "composer.lock"), newLockfileOptions{skipLoading: true})
+		assert.Nil(t, err)
+		assert.Nil(t, Rollback(file))
+		assertRestored(t, projectDir, vendorDir)
+	})
+
+	t.Run("incremental install stopped part way", func(t *testing.T) {
+		projectDir, vendorDir, cleanup := setup(t)
+		defer cleanup()
+		s, err := newSnapshot(projectDir, false)
+		assert.Nil(t, err)
+		s.Replaced = []string{"acme/a", "acme/b"}
+		s.Added = []string{"acme/c"}
+		assert.Nil(t, s.save())
+		writeFiles(t, s.vendorDir(), map[string]string{"autoload.php": "<?php"})
+		// acme/a was moved aside and replaced, acme/b was not reached yet.
+		assert.Nil(t, os.MkdirAll(filepath.Join(s.vendorDir(), "acme"), 0755))
+		assert.Nil(t, os.Rename(filepath.Join(vendorDir, "acme", "a"), filepath.Join(s.vendorDir(), "acme", "a")))
+		writeFiles(t, vendorDir, map[string]string{"acme/a/composer.json": "new a", "acme/c/composer.json": "c"})
+
+		recovered, err := recoverInstall(projectDir)
+		assert.Nil(t, err)
+		assert.True(t, recovered)
+		assertRestored(t, projectDir, vendorDir)
+	})
+
+	t.Run("install stopped before saving", func(t *testing.T) {
+		projectDir, vendorDir, cleanup := setup(t)
+		defer cleanup()
+		_, err := newSnapshot(projectDir, true)
+		assert.Nil(t, err)
+
+		recovered, err := recoverInstall(projectDir)
+		assert.Nil(t, err)
+		assert.True(t, recovered)
+		assertRestored(t, projectDir, vendorDir)
+	})
+
+	t.Run("next install recovers first", func(t *testing.T) {
+		projectDir, vendorDir, cleanup := setup(t)
+		defer cleanup()
+		s, err := newSnapshot(projectDir, true)
+		assert.Nil(t, err)
+		s.Previous = true
+		assert.Nil(t, s.save())
+		assert.Nil(t, os.Rename(vendorDir, s.vendorDir()))
+
+		file, err := newLockfile(filepath.Join(projectDir, "composer.lock"), newLockfileOptions{skipLoading: true})
+		assert.Nil(t, err)
+		file.Packages = []Package{{Name: "acme/a", Version: "1.0.0", Distribution: Distribution{Type: "path", URL: "missing"}}}
+		assert.NotNil(t, Install(file, InstallOptions{Quiet: true}))
+		assertRestored(t, projectDir, vendorDir)
+	})
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hasDist reports whether a package is locked with a dist to install from.
+func hasDist(p Package) bool {
+	return p.Distribution.URL != ""
+}
+
+// hasSource reports whether a package is locked with a source repository.
+func hasSource(p Package) bool {
+	return p.Source.URL != "" && p.Source.Reference != ""
+}
+
+// installFromSource reports whether a package should be cloned from its
+// source repository instead of downloaded as a dist.
+func installFromSource(p Package, preferSource bool) bool {
+	if !hasSource(p) {
+		return false
+	}
+	return preferSource || !hasDist(p)
+}
+
+// installSourcePackage clones the source repository of a package into its
+// install path within dir and checks out the locked reference.
+func installSourcePackage(dir string, p Package) error {
+	if p.Source.Type != "git" {
+		return fmt.Errorf("unable to install %s from source: unsupported source type %q", p.Name, p.Source.Type)
+	}
+	// A reference git would parse as an option is never a commit.
+	if strings.HasPrefix(p.Source.Reference, "-") {
+		return fmt.Errorf("unable to install %s from source: invalid reference %q", p.Name, p.Source.Reference)
+	}
+	packagePath, err := installPath(dir, p)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(packagePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = git("", "clone", "--quiet", "--no-checkout", "--", p.Source.URL, packagePath)
+	if err != nil {
+		return fmt.Errorf("unable to clone %s: %w", p.Name, err)
+	}
+	err = git(packagePath, "checkout", "--quiet", "--detach", p.Source.Reference)
+	if err != nil {
+		return fmt.Errorf("unable to check out %s at %s: %w", p.Name, p.Source.Reference, err)
+	}
+	return nil
+}
+
+// git runs the local git binary within dir, including its output in any
+// returned error.
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never wait on a credential prompt that nobody will answer.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallSourcePackages(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	root, err := ioutil.TempDir("", "compote_source_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	bare, locked := bareRepository(t, root)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipArchive(t, "acme-foo-dist/", map[string]string{"composer.json": `{"version": "dist"}`}))
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		pkg          Package
+		preferSource bool
+		contents     string
+	}{
+		"source-only package is cloned": {
+			pkg: Package{
+				Name:   "acme/foo",
+				Source: Source{Type: "git", URL: bare, Reference: locked},
+			},
+			contents: `{"version": "locked"}`,
+		},
+		"dist is preferred by default": {
+			pkg: Package{
+				Name:         "acme/foo",
+				Source:       Source{Type: "git", URL: bare, Reference: locked},
+				Distribution: Distribution{Type: "zip", URL: server.URL + "/acme/foo.zip"},
+			},
+			contents: `{"version": "dist"}`,
+		},
+		"source is cloned when preferred": {
+			pkg: Package{
+				Name:         "acme/foo",
+				Source:       Source{Type: "git", URL: bare, Reference: locked},
+				Distribution: Distribution{Type: "zip", URL: server.URL + "/acme/foo.zip"},
+			},
+			preferSource: true,
+			contents:     `{"version": "locked"}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, cleanup := newTestLockfile(t, tc.pkg)
+			defer cleanup()
+			assert.Nil(t, Install(file, InstallOptions{Quiet: true, PreferSource: tc.preferSource}))
+			contents, err := ioutil.ReadFile(filepath.Join(file.Dirpath(), "vendor", "acme", "foo", "composer.json"))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.contents, string(contents))
+		})
+	}
+}
+
+func TestInstallSourcePackageFailures(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "compote_source_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	marker := filepath.Join(tmp, "marker")
+
+	tests := map[string]struct {
+		source  Source
+		message string
+	}{
+		"unsupported source type": {
+			source: Source{Type: "hg", URL: "https://example.com/acme/foo", Reference: "abc123"},
+		},
+		"missing repository": {
+			source: Source{Type: "git", URL: filepath.Join(os.TempDir(), "compote-missing-repository"), Reference: "abc123"},
+		},
+		"url is not parsed as an option": {
+			source:  Source{Type: "git", URL: "--upload-pack=touch " + marker, Reference: "abc123"},
+			message: "repository '--upload-pack=touch " + marker + "' does not exist",
+		},
+		"reference is not parsed as an option": {
+			source:  Source{Type: "git", URL: filepath.Join(os.TempDir(), "compote-missing-repository"), Reference: "--orphan=abc123"},
+			message: `invalid reference "--orphan=abc123"`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, cleanup := newTestLockfile(t, Package{Name: "acme/foo", Source: tc.source})
+			defer cleanup()
+			err := Install(file, InstallOptions{Quiet: true})
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "acme/foo")
+			assert.Contains(t, err.Error(), tc.message)
+			assert.False(t, exists(marker))
+		})
+	}
+}
+
+// bareRepository creates a bare git repository within dir with two commits
+// and returns its path along with the hash of the first commit.
+func bareRepository(t *testing.T, dir string) (string, string) {
+	work := filepath.Join(dir, "work")
+	bare := filepath.Join(dir, "foo.git")
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=compote", "GIT_AUTHOR_EMAIL=compote@example.com",
+			"GIT_COMMITTER_NAME=compote", "GIT_COMMITTER_EMAIL=compote@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	assert.Nil(t, os.MkdirAll(work, 0755))
+	run(work, "init", "--quiet")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(work, "composer.json"), []byte(`{"version": "locked"}`), 0644))
+	run(work, "add", "composer.json")
+	run(work, "commit", "--quiet", "-m", "locked")
+	locked := run(work, "rev-parse", "HEAD")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(work, "composer.json"), []byte(`{"version": "newer"}`), 0644))
+	run(work, "commit", "--quiet", "-am", "newer")
+	run(dir, "clone", "--quiet", "--bare", work, bare)
+	return bare, locked
+}